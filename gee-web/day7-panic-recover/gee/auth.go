@@ -0,0 +1,106 @@
+package gee
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// AuthUserKey is the context key BasicAuth stores the user name with
+const AuthUserKey = "user"
+
+// Accounts maps user names to passwords for BasicAuth
+type Accounts map[string]string
+
+// BasicAuth checks the HTTP Basic credentials against accounts,
+// see BasicAuthForRealm
+func BasicAuth(accounts Accounts) HandlerFunc {
+	return BasicAuthForRealm(accounts, "")
+}
+
+// BasicAuthForRealm checks the HTTP Basic credentials against accounts
+// and stores the user name with AuthUserKey. Passwords are compared in
+// constant time. Other requests are answered with 401 and a challenge
+// for realm, "Authorization Required" if it is empty.
+func BasicAuthForRealm(accounts Accounts, realm string) HandlerFunc {
+	if realm == "" {
+		realm = "Authorization Required"
+	}
+	challenge := "Basic realm=" + strconv.Quote(realm) + `, charset="UTF-8"`
+	// hashing gives the compared values the same length, so
+	// the comparison doesn't leak the length of the passwords
+	hashes := make(map[string][sha256.Size]byte, len(accounts))
+	for user, password := range accounts {
+		hashes[user] = sha256.Sum256([]byte(password))
+	}
+	var missing [sha256.Size]byte
+
+	return func(c *Context) {
+		user, password, ok := c.Req.BasicAuth()
+		if ok {
+			expected, found := hashes[user]
+			if !found {
+				expected = missing
+			}
+			given := sha256.Sum256([]byte(password))
+			if subtle.ConstantTimeCompare(given[:], expected[:]) == 1 && found {
+				c.Set(AuthUserKey, user)
+				c.Next()
+				return
+			}
+		}
+		unauthorized(c, challenge)
+	}
+}
+
+// BearerAuth passes the token of the Authorization: Bearer header to
+// validate, which may store what it finds with c.Set. Requests without
+// a token or for which validate returns an error are answered with 401.
+func BearerAuth(validate func(c *Context, token string) error) HandlerFunc {
+	return bearerAuth("", validate)
+}
+
+func bearerAuth(realm string, validate func(c *Context, token string) error) HandlerFunc {
+	challenge := "Bearer"
+	if realm != "" {
+		challenge += " realm=" + strconv.Quote(realm)
+	}
+	return func(c *Context) {
+		token, ok := bearerToken(c.Req)
+		if !ok {
+			unauthorized(c, challenge)
+			return
+		}
+		if err := validate(c, token); err != nil {
+			sep := ","
+			if realm == "" {
+				sep = ""
+			}
+			// the error may tell internals, only the log gets it
+			c.Error(err)
+			unauthorized(c, challenge+sep+` error="invalid_token", error_description="the token is invalid"`)
+			return
+		}
+		c.Next()
+	}
+}
+
+// bearerToken returns the token of the Authorization header
+func bearerToken(req *http.Request) (string, bool) {
+	auth := req.Header.Get("Authorization")
+	const prefix = "bearer "
+	if len(auth) <= len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return "", false
+	}
+	token := strings.TrimSpace(auth[len(prefix):])
+	return token, token != ""
+}
+
+// unauthorized aborts with 401 and the challenge in WWW-Authenticate
+func unauthorized(c *Context, challenge string) {
+	c.SetHeader("WWW-Authenticate", challenge)
+	c.Abort()
+	c.String(http.StatusUnauthorized, "401 UNAUTHORIZED\n")
+}
@@ -0,0 +1,202 @@
+package gee
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func serveAuth(r *Engine, authorization string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/", nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestBasicAuth(t *testing.T) {
+	r := New()
+	r.Use(BasicAuthForRealm(Accounts{"gee": "secret"}, "admin"))
+	r.GET("/", func(c *Context) {
+		c.String(http.StatusOK, c.GetString(AuthUserKey))
+	})
+	basic := func(user, password string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+	}
+
+	if w := serveAuth(r, basic("gee", "secret")); w.Code != http.StatusOK || w.Body.String() != "gee" {
+		t.Fatalf("valid credentials answered %d %q", w.Code, w.Body.String())
+	}
+	for _, auth := range []string{"", basic("gee", "wrong"), basic("other", "secret"), "Bearer x"} {
+		w := serveAuth(r, auth)
+		if w.Code != http.StatusUnauthorized ||
+			w.Header().Get("WWW-Authenticate") != `Basic realm="admin", charset="UTF-8"` {
+			t.Fatalf("%q answered %d %v", auth, w.Code, w.Header())
+		}
+	}
+}
+
+func TestBearerAuth(t *testing.T) {
+	var logged error
+	r := New()
+	r.Use(func(c *Context) {
+		c.Next()
+		if len(c.Errors) > 0 {
+			logged = c.Errors.Last()
+		}
+	})
+	r.Use(BearerAuth(func(c *Context, token string) error {
+		if token != "valid" {
+			return errors.New("unknown token")
+		}
+		c.Set("token", token)
+		return nil
+	}))
+	r.GET("/", func(c *Context) {
+		c.String(http.StatusOK, c.GetString("token"))
+	})
+
+	if w := serveAuth(r, "bearer valid"); w.Code != http.StatusOK || w.Body.String() != "valid" {
+		t.Fatalf("valid token answered %d %q", w.Code, w.Body.String())
+	}
+	if w := serveAuth(r, ""); w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") != "Bearer" {
+		t.Fatalf("missing token answered %d %v", w.Code, w.Header())
+	}
+	w := serveAuth(r, "Bearer stolen")
+	if w.Code != http.StatusUnauthorized ||
+		w.Header().Get("WWW-Authenticate") != `Bearer error="invalid_token", error_description="the token is invalid"` {
+		t.Fatalf("invalid token answered %d %v", w.Code, w.Header())
+	}
+	if logged == nil || logged.Error() != "unknown token" {
+		t.Fatalf("the validator error should be logged, got %v", logged)
+	}
+}
+
+// signJWT builds a token, key is a []byte for HS256 or an *rsa.PrivateKey
+func signJWT(t *testing.T, alg string, key interface{}, claims H) string {
+	t.Helper()
+	header, _ := json.Marshal(H{"alg": alg, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	var sig []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		hash := sha256.Sum256([]byte(signed))
+		var err error
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, hash[:]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestParseJWT(t *testing.T) {
+	secret := []byte("secret")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1600000000, 0)
+	config := JWTConfig{
+		Key:       secret,
+		PublicKey: &rsaKey.PublicKey,
+		Audience:  "api",
+		Issuer:    "gee",
+		Leeway:    time.Minute,
+		Now:       func() time.Time { return now },
+	}
+	claims := func(extra H) H {
+		c := H{"sub": "42", "iss": "gee", "aud": []string{"web", "api"}, "exp": now.Unix() + 60}
+		for k, v := range extra {
+			c[k] = v
+		}
+		return c
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"hs256", signJWT(t, "HS256", secret, claims(nil)), nil},
+		{"rs256", signJWT(t, "RS256", rsaKey, claims(H{"aud": "api"})), nil},
+		{"expired within leeway", signJWT(t, "HS256", secret, claims(H{"exp": now.Unix() - 30})), nil},
+		{"expired", signJWT(t, "HS256", secret, claims(H{"exp": now.Unix() - 120})), ErrTokenExpired},
+		{"not valid yet", signJWT(t, "HS256", secret, claims(H{"nbf": now.Unix() + 120})), ErrTokenNotValidYet},
+		{"wrong audience", signJWT(t, "HS256", secret, claims(H{"aud": "web"})), ErrTokenAudience},
+		{"wrong issuer", signJWT(t, "HS256", secret, claims(H{"iss": "evil"})), ErrTokenIssuer},
+		{"exp not a number", signJWT(t, "HS256", secret, claims(H{"exp": "soon"})), ErrTokenMalformed},
+		{"exp after 2262", signJWT(t, "HS256", secret, claims(H{"exp": 1e13})), nil},
+		{"exp out of range", signJWT(t, "HS256", secret, claims(H{"exp": 1e300})), ErrTokenMalformed},
+		{"wrong secret", signJWT(t, "HS256", []byte("guess"), claims(nil)), ErrTokenSignature},
+		{"none", signJWT(t, "none", nil, claims(nil)), ErrTokenAlgorithm},
+		{"malformed", "a.b", ErrTokenMalformed},
+	}
+	for _, tt := range tests {
+		c, err := ParseJWT(tt.token, config)
+		if err != tt.err {
+			t.Fatalf("%s: got %v, want %v", tt.name, err, tt.err)
+		}
+		if err == nil && c.Subject() != "42" {
+			t.Fatalf("%s: subject %q", tt.name, c.Subject())
+		}
+	}
+
+	// an RS256 public key must not be accepted as HS256 secret
+	hsOnly := config
+	hsOnly.Key = nil
+	if _, err := ParseJWT(signJWT(t, "HS256", secret, claims(nil)), hsOnly); err != ErrTokenAlgorithm {
+		t.Fatalf("HS256 token accepted without a secret: %v", err)
+	}
+	hsOnly.Key = []byte("")
+	if _, err := ParseJWT(signJWT(t, "HS256", []byte(""), claims(nil)), hsOnly); err != ErrTokenAlgorithm {
+		t.Fatalf("HS256 token accepted with an empty secret: %v", err)
+	}
+}
+
+func TestJWTEmptyKey(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("JWT should panic on an empty key")
+		}
+	}()
+	JWT(JWTConfig{Key: []byte("")})
+}
+
+func TestJWT(t *testing.T) {
+	secret := []byte("secret")
+	r := New()
+	r.Use(JWT(JWTConfig{Key: secret, Realm: "api"}))
+	r.GET("/", func(c *Context) {
+		c.String(http.StatusOK, c.MustGet(JWTClaimsKey).(JWTClaims).Subject())
+	})
+
+	token := signJWT(t, "HS256", secret, H{"sub": "42", "exp": time.Now().Add(time.Hour).Unix()})
+	if w := serveAuth(r, "Bearer "+token); w.Code != http.StatusOK || w.Body.String() != "42" {
+		t.Fatalf("valid token answered %d %q", w.Code, w.Body.String())
+	}
+	expired := signJWT(t, "HS256", secret, H{"sub": "42", "exp": time.Now().Add(-time.Hour).Unix()})
+	w := serveAuth(r, "Bearer "+expired)
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") !=
+		`Bearer realm="api", error="invalid_token", error_description="the token is invalid"` {
+		t.Fatalf("expired token answered %d %v", w.Code, w.Header())
+	}
+	if w := serveAuth(r, ""); w.Header().Get("WWW-Authenticate") != `Bearer realm="api"` {
+		t.Fatalf("missing token answered %v", w.Header())
+	}
+}
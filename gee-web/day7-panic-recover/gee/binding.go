@@ -0,0 +1,271 @@
+package gee
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Bind picks the decoder from the method and Content-Type, fills obj and
+// validates it. On error the chain is aborted with a 400 ErrorTypeBind
+// error, whose meta holds the ValidationErrors if validation failed.
+func (c *Context) Bind(obj interface{}) error {
+	if err := c.ShouldBind(obj); err != nil {
+		code := http.StatusBadRequest
+		if errors.Is(err, ErrBodyTooLarge) {
+			code = http.StatusRequestEntityTooLarge
+		}
+		e := c.AbortWithError(code, err).SetType(ErrorTypeBind)
+		var verrs ValidationErrors
+		if errors.As(err, &verrs) {
+			e.SetMeta(verrs)
+		}
+		return err
+	}
+	return nil
+}
+
+// ShouldBind is like Bind but leaves the response to the caller
+func (c *Context) ShouldBind(obj interface{}) error {
+	if c.Method == http.MethodGet || c.Method == http.MethodHead {
+		return c.ShouldBindQuery(obj)
+	}
+	contentType, _, _ := mime.ParseMediaType(c.Req.Header.Get("Content-Type"))
+	switch contentType {
+	case "application/json":
+		return c.ShouldBindJSON(obj)
+	default:
+		return c.ShouldBindForm(obj)
+	}
+}
+
+// ShouldBindJSON decodes the request body as JSON into obj and validates it
+func (c *Context) ShouldBindJSON(obj interface{}) error {
+	if c.Req.Body == nil {
+		return errors.New("gee: missing request body")
+	}
+	if err := json.NewDecoder(c.Req.Body).Decode(obj); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+// ShouldBindQuery fills obj from the query string using `form` tags
+func (c *Context) ShouldBindQuery(obj interface{}) error {
+	if err := mapForm(obj, c.Req.URL.Query(), "form"); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+// ShouldBindForm fills obj from the query string and the url-encoded
+// or multipart body using `form` tags
+func (c *Context) ShouldBindForm(obj interface{}) error {
+	if err := c.Req.ParseMultipartForm(c.engine.MaxMultipartMemory); err != nil && err != http.ErrNotMultipart {
+		return err
+	}
+	if err := mapForm(obj, c.Req.Form, "form"); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+// ShouldBindUri fills obj from the route params using `uri` tags
+func (c *Context) ShouldBindUri(obj interface{}) error {
+	values := make(map[string][]string, len(c.Params))
+	for _, p := range c.Params {
+		values[p.Key] = []string{p.Value}
+	}
+	if err := mapForm(obj, values, "uri"); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+// mapForm sets the fields of the struct ptr points to from values,
+// looking the fields up by the name in tag or else by field name
+func mapForm(ptr interface{}, values map[string][]string, tag string) error {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("gee: bind target must be a pointer to struct, got %T", ptr)
+	}
+	return mapStruct(v.Elem(), values, tag)
+}
+
+func mapStruct(v reflect.Value, values map[string][]string, tag string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && (!field.Anonymous || field.Type.Kind() != reflect.Struct) {
+			// unexported, the exported fields of an embedded struct are still set
+			continue
+		}
+		name, ok := field.Tag.Lookup(tag)
+		if name == "-" {
+			continue
+		}
+		if idx := strings.IndexByte(name, ','); idx >= 0 {
+			name = name[:idx]
+		}
+		fv := v.Field(i)
+		if !ok && field.Type.Kind() == reflect.Struct && field.Type != timeType {
+			if err := mapStruct(fv, values, tag); err != nil {
+				return err
+			}
+			continue
+		}
+		if !ok && field.Anonymous && field.Type.Kind() == reflect.Ptr &&
+			field.Type.Elem().Kind() == reflect.Struct && field.Type.Elem() != timeType {
+			// an embedded pointer is only allocated if one of its fields is bound
+			inner := fv
+			if fv.IsNil() {
+				inner = reflect.New(field.Type.Elem())
+			}
+			if err := mapStruct(inner.Elem(), values, tag); err != nil {
+				return err
+			}
+			if fv.IsNil() && !inner.Elem().IsZero() {
+				fv.Set(inner)
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		vs, ok := values[name]
+		if !ok {
+			continue
+		}
+		if err := setField(fv, field, vs); err != nil {
+			return fmt.Errorf("gee: bind %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func setField(v reflect.Value, field reflect.StructField, vs []string) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setField(v.Elem(), field, vs)
+	case reflect.Slice:
+		slice := reflect.MakeSlice(v.Type(), len(vs), len(vs))
+		for i, s := range vs {
+			if err := setValue(slice.Index(i), field, s); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+	if len(vs) == 0 {
+		return nil
+	}
+	return setValue(v, field, vs[0])
+}
+
+func setValue(v reflect.Value, field reflect.StructField, s string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	if v.Type() == timeType {
+		return setTime(v, field, s)
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		if s == "" {
+			s = "false"
+		}
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Type() == reflect.TypeOf(time.Duration(0)) {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return err
+			}
+			v.SetInt(int64(d))
+			return nil
+		}
+		if s == "" {
+			s = "0"
+		}
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if s == "" {
+			s = "0"
+		}
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		if s == "" {
+			s = "0"
+		}
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// setTime parses s with the layout in the `time_format` tag, RFC 3339 by
+// default, or as seconds or nanoseconds since epoch for "unix" and "unixnano"
+func setTime(v reflect.Value, field reflect.StructField, s string) error {
+	if s == "" {
+		v.Set(reflect.ValueOf(time.Time{}))
+		return nil
+	}
+	layout := field.Tag.Get("time_format")
+	var t time.Time
+	switch layout {
+	case "unix", "unixnano":
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		if layout == "unix" {
+			t = time.Unix(n, 0)
+		} else {
+			t = time.Unix(0, n)
+		}
+	default:
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		var err error
+		if t, err = time.Parse(layout, s); err != nil {
+			return err
+		}
+	}
+	v.Set(reflect.ValueOf(t))
+	return nil
+}
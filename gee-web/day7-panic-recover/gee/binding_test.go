@@ -0,0 +1,189 @@
+package gee
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type bindUser struct {
+	ID       int       `uri:"id" form:"id" json:"id"`
+	Name     string    `form:"name" json:"name" binding:"required,min=1,max=8"`
+	Email    string    `form:"email" json:"email" binding:"omitempty,email"`
+	Role     string    `form:"role" json:"role" binding:"oneof=admin guest"`
+	Tags     []string  `form:"tag" json:"tags"`
+	Age      *int      `form:"age" json:"age" binding:"min=18"`
+	Birthday time.Time `form:"birthday" time_format:"2006-01-02" json:"-"`
+}
+
+func serveBind(method, target, contentType, body string, handler HandlerFunc) *httptest.ResponseRecorder {
+	r := New()
+	r.Any("/users/:id", handler)
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestShouldBindQuery(t *testing.T) {
+	var u bindUser
+	target := "/users/7?name=gee&email=gee@example.com&role=admin&tag=a&tag=b&age=20&birthday=2020-01-09"
+	serveBind("GET", target, "", "", func(c *Context) {
+		if err := c.ShouldBind(&u); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.ShouldBindUri(&u); err != nil {
+			t.Fatal(err)
+		}
+	})
+	birthday := time.Date(2020, 1, 9, 0, 0, 0, 0, time.UTC)
+	if u.ID != 7 || u.Name != "gee" || u.Email != "gee@example.com" || u.Role != "admin" {
+		t.Fatalf("unexpected binding %+v", u)
+	}
+	if !reflect.DeepEqual(u.Tags, []string{"a", "b"}) || u.Age == nil || *u.Age != 20 || !u.Birthday.Equal(birthday) {
+		t.Fatalf("unexpected binding %+v", u)
+	}
+}
+
+func TestShouldBindForm(t *testing.T) {
+	var u bindUser
+	serveBind("POST", "/users/1", "application/x-www-form-urlencoded", "name=gee&role=guest&tag=x", func(c *Context) {
+		if err := c.ShouldBind(&u); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if u.Name != "gee" || u.Role != "guest" || len(u.Tags) != 1 || u.Age != nil {
+		t.Fatalf("unexpected binding %+v", u)
+	}
+
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	mw.WriteField("name", "multi")
+	mw.WriteField("role", "admin")
+	mw.Close()
+	u = bindUser{}
+	serveBind("PUT", "/users/1", mw.FormDataContentType(), body.String(), func(c *Context) {
+		if err := c.ShouldBind(&u); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if u.Name != "multi" || u.Role != "admin" {
+		t.Fatalf("unexpected binding %+v", u)
+	}
+}
+
+func TestShouldBindJSON(t *testing.T) {
+	var u bindUser
+	body := `{"name":"gee","email":"gee@example.com","role":"guest","tags":["a"],"age":30}`
+	serveBind("POST", "/users/1", "application/json; charset=utf-8", body, func(c *Context) {
+		if err := c.ShouldBind(&u); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if u.Name != "gee" || *u.Age != 30 || u.Tags[0] != "a" {
+		t.Fatalf("unexpected binding %+v", u)
+	}
+}
+
+func TestBindValidation(t *testing.T) {
+	var err error
+	body := `{"name":"too long name","email":"Gee <gee@example.com>","role":"root","age":3}`
+	w := serveBind("POST", "/users/1", "application/json", body, func(c *Context) {
+		var u bindUser
+		err = c.Bind(&u)
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status should be 400, got %d", w.Code)
+	}
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) {
+		t.Fatalf("error should be ValidationErrors, got %v", err)
+	}
+	want := []FieldError{
+		{Field: "bindUser.Name", Rule: "max", Param: "8"},
+		{Field: "bindUser.Email", Rule: "email"},
+		{Field: "bindUser.Role", Rule: "oneof", Param: "admin guest"},
+		{Field: "bindUser.Age", Rule: "min", Param: "18"},
+	}
+	if len(verrs) != len(want) {
+		t.Fatalf("should have %d errors, got %v", len(want), verrs)
+	}
+	for i, fe := range verrs {
+		if fe.Field != want[i].Field || fe.Rule != want[i].Rule || fe.Param != want[i].Param {
+			t.Fatalf("error %d should be %+v, got %+v", i, want[i], fe)
+		}
+	}
+
+	err = validate(&bindUser{Role: "guest"})
+	if !errors.As(err, &verrs) || len(verrs) != 2 || verrs[0].Rule != "required" {
+		t.Fatalf("empty name should fail required and min, got %v", err)
+	}
+}
+
+func TestValidateNested(t *testing.T) {
+	type address struct {
+		City string `binding:"required"`
+	}
+	type order struct {
+		Items   []int `binding:"min=1"`
+		Address *address
+	}
+	err := validate(&order{Items: []int{1}, Address: &address{}})
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) || len(verrs) != 1 || verrs[0].Field != "order.Address.City" {
+		t.Fatalf("nested struct should be validated, got %v", err)
+	}
+	if err := validate(&struct {
+		N int `binding:"between=1"`
+	}{}); err == nil || errors.As(err, &verrs) {
+		t.Fatalf("unknown rule should be reported as a plain error, got %v", err)
+	}
+}
+
+type bindTag string
+
+type BindPage struct {
+	Page int `form:"page"`
+	Size int `form:"size"`
+}
+
+func TestBindEmbedded(t *testing.T) {
+	var q struct {
+		bindTag
+		*BindPage
+		Name string `form:"name"`
+	}
+	var empty struct {
+		*BindPage
+		Name string `form:"name"`
+	}
+	w := serveBind("GET", "/users/1?tag=x&page=2&size=10&name=gee", "", "", func(c *Context) {
+		if err := c.ShouldBindQuery(&q); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("binding answered %d %q", w.Code, w.Body.String())
+	}
+	if q.bindTag != "" || q.BindPage == nil || q.Page != 2 || q.Size != 10 || q.Name != "gee" {
+		t.Fatalf("unexpected binding %+v %+v", q, q.BindPage)
+	}
+
+	serveBind("GET", "/users/1?name=gee", "", "", func(c *Context) {
+		if err := c.ShouldBindQuery(&empty); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if empty.BindPage != nil || empty.Name != "gee" {
+		t.Fatalf("an embedded pointer without values was allocated: %+v", empty.BindPage)
+	}
+}
@@ -1,38 +1,129 @@
 package gee
 
 import (
-	"encoding/json"
-	"fmt"
+	"io"
+	"math"
+	"net"
 	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gee/render"
 )
 
 type H map[string]interface{}
 
+// Param is a single URL parameter, consisting of a key and a value
+type Param struct {
+	Key   string
+	Value string
+}
+
+// Params is a Param-slice, as filled by the router.
+// It is reused across requests to avoid allocating a map.
+type Params []Param
+
+// Get returns the value of the first Param which key matches the given name
+func (ps Params) Get(name string) (string, bool) {
+	for _, p := range ps {
+		if p.Key == name {
+			return p.Value, true
+		}
+	}
+	return "", false
+}
+
+// ByName returns the value of the first Param which key matches the given name,
+// or an empty string if there is none
+func (ps Params) ByName(name string) string {
+	value, _ := ps.Get(name)
+	return value
+}
+
 type Context struct {
 	// origin objects
-	Writer http.ResponseWriter
-	Req    *http.Request
+	writermem responseWriter
+	Writer    ResponseWriter
+	Req       *http.Request
 	// request info
-	Path   string
-	Method string
-	Params map[string]string
-	// response info
+	Path     string
+	Method   string
+	Params   Params
+	fullPath string // pattern of the matched route
+	// response info, the status requested by Status,
+	// see Writer.Status() for the status of the response
 	StatusCode int
 	// middleware
 	handlers []HandlerFunc
 	index    int
+	// errors collected by Error, rendered by the engine's error handler
+	Errors Errors
+	// per-request key/value store, shared by middlewares and handlers
+	mu   sync.RWMutex
+	Keys map[string]interface{}
 	// engine pointer
 	engine *Engine
+	// goroutines using the Context, it goes back to the pool
+	// once they are all done, see Timeout
+	refs int32
+}
+
+// abortIndex is larger than any handler chain, see Abort
+const abortIndex int = math.MaxInt32 / 2
+
+// reset prepares a pooled Context for the next request,
+// every per-request field must be cleared here
+func (c *Context) reset(w http.ResponseWriter, req *http.Request) {
+	c.writermem.reset(w)
+	c.Writer = &c.writermem
+	c.Req = req
+	c.Path = req.URL.Path
+	c.Method = req.Method
+	c.Params = c.Params[:0]
+	c.fullPath = ""
+	c.StatusCode = 0
+	c.handlers = nil
+	c.index = -1
+	c.Errors = c.Errors[:0]
+	c.Keys = nil
+	c.refs = 1
+}
+
+// release returns c to the pool of its engine once the last goroutine
+// holding it is done, c must not be used by the caller afterwards
+func (c *Context) release() {
+	if atomic.AddInt32(&c.refs, -1) == 0 {
+		c.engine.pool.Put(c)
+	}
 }
 
-func newContext(w http.ResponseWriter, req *http.Request) *Context {
-	return &Context{
-		Path:   req.URL.Path,
-		Method: req.Method,
-		Req:    req,
-		Writer: w,
-		index:  -1,
+// Copy returns a copy of the current context that can be safely used
+// outside the request's scope, e.g. when it is passed to a goroutine.
+// The Context itself is reused for another request once the handler
+// chain returns. The copy has no Writer and its handler chain is empty.
+func (c *Context) Copy() *Context {
+	cp := &Context{
+		Req:        c.Req,
+		Path:       c.Path,
+		Method:     c.Method,
+		fullPath:   c.fullPath,
+		StatusCode: c.StatusCode,
+		index:      -1,
+		engine:     c.engine,
 	}
+	cp.Params = make(Params, len(c.Params))
+	copy(cp.Params, c.Params)
+	c.mu.RLock()
+	if c.Keys != nil {
+		cp.Keys = make(map[string]interface{}, len(c.Keys))
+		for k, v := range c.Keys {
+			cp.Keys[k] = v
+		}
+	}
+	c.mu.RUnlock()
+	return cp
 }
 
 func (c *Context) Next() {
@@ -43,14 +134,51 @@ func (c *Context) Next() {
 	}
 }
 
+// Abort prevents pending handlers from being called, the current
+// handler still runs to its end. Use it e.g. when authorization fails.
+func (c *Context) Abort() {
+	c.index = abortIndex
+}
+
+// IsAborted returns true if the current context was aborted
+func (c *Context) IsAborted() bool {
+	return c.index >= abortIndex
+}
+
+// AbortWithStatus calls Abort and writes the headers with the status code
+func (c *Context) AbortWithStatus(code int) {
+	c.Status(code)
+	c.Writer.WriteHeaderNow()
+	c.Abort()
+}
+
+// AbortWithStatusJSON calls Abort and writes obj as JSON with the status code
+func (c *Context) AbortWithStatusJSON(code int, obj interface{}) {
+	c.Abort()
+	c.JSON(code, obj)
+}
+
+// AbortWithError calls Abort and collects err. Nothing is written,
+// the error handler of the engine renders err with the status code.
+func (c *Context) AbortWithError(code int, err error) *Error {
+	c.Status(code)
+	c.Abort()
+	return c.Error(err)
+}
+
+// Fail aborts the chain with a JSON message, like AbortWithStatusJSON
 func (c *Context) Fail(code int, err string) {
-	c.index = len(c.handlers)
-	c.JSON(code, H{"message": err})
+	c.AbortWithStatusJSON(code, H{"message": err})
 }
 
 func (c *Context) Param(key string) string {
-	value, _ := c.Params[key]
-	return value
+	return c.Params.ByName(key)
+}
+
+// FullPath returns the pattern of the matched route, e.g. "/users/:id",
+// or "" if no route matched
+func (c *Context) FullPath() string {
+	return c.fullPath
 }
 
 func (c *Context) PostForm(key string) string {
@@ -61,6 +189,32 @@ func (c *Context) Query(key string) string {
 	return c.Req.URL.Query().Get(key)
 }
 
+// ClientIP returns the IP of the client. The X-Forwarded-For and
+// X-Real-IP headers are only read if the engine's ForwardedByClientIP
+// is set, as clients can send any value when there is no proxy.
+func (c *Context) ClientIP() string {
+	if c.engine != nil && c.engine.ForwardedByClientIP {
+		if fwd := c.Req.Header.Get("X-Forwarded-For"); fwd != "" {
+			if i := strings.IndexByte(fwd, ','); i >= 0 {
+				fwd = fwd[:i]
+			}
+			if ip := strings.TrimSpace(fwd); ip != "" {
+				return ip
+			}
+		}
+		if ip := strings.TrimSpace(c.Req.Header.Get("X-Real-IP")); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(strings.TrimSpace(c.Req.RemoteAddr))
+	if err != nil {
+		return c.Req.RemoteAddr
+	}
+	return host
+}
+
+// Status sets the status of the response, it is sent
+// with the first write to the body or when the chain finishes
 func (c *Context) Status(code int) {
 	c.StatusCode = code
 	c.Writer.WriteHeader(code)
@@ -70,32 +224,244 @@ func (c *Context) SetHeader(key string, value string) {
 	c.Writer.Header().Set(key, value)
 }
 
+// Render writes the status and r. On a status without a body only the
+// Content-Type is set. If r fails before writing, the chain is aborted
+// and the error handler answers with 500. A code < 0 keeps the status,
+// e.g. for redirects which set their own.
+func (c *Context) Render(code int, r render.Render) {
+	if code > 0 {
+		c.Status(code)
+	}
+	if !bodyAllowedForStatus(c.Writer.Status()) {
+		r.WriteContentType(c.Writer)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+	if err := r.Render(c.Writer); err != nil {
+		if c.Writer.Written() {
+			c.Abort()
+			c.Error(err)
+			return
+		}
+		c.AbortWithError(http.StatusInternalServerError, err)
+	}
+}
+
+// bodyAllowedForStatus reports whether a response with status may have a body
+func bodyAllowedForStatus(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent, status == http.StatusNotModified:
+		return false
+	}
+	return true
+}
+
 func (c *Context) String(code int, format string, values ...interface{}) {
-	c.SetHeader("Content-Type", "text/plain")
-	c.Status(code)
-	c.Writer.Write([]byte(fmt.Sprintf(format, values...)))
+	c.Render(code, render.String{Format: format, Data: values})
 }
 
 func (c *Context) JSON(code int, obj interface{}) {
-	c.SetHeader("Content-Type", "application/json")
-	c.Status(code)
-	encoder := json.NewEncoder(c.Writer)
-	if err := encoder.Encode(obj); err != nil {
-		http.Error(c.Writer, err.Error(), 500)
-	}
+	c.Render(code, render.JSON{Data: obj})
+}
+
+// IndentedJSON writes obj as pretty printed JSON, mind it is
+// larger and slower than JSON, use it for debugging only
+func (c *Context) IndentedJSON(code int, obj interface{}) {
+	c.Render(code, render.IndentedJSON{Data: obj})
+}
+
+// SecureJSON writes obj as JSON, arrays are prefixed
+// with the SecureJSONPrefix of the engine
+func (c *Context) SecureJSON(code int, obj interface{}) {
+	c.Render(code, render.SecureJSON{Prefix: c.engine.SecureJSONPrefix, Data: obj})
+}
+
+// JSONP writes obj as JSON wrapped in the function named by
+// the callback query parameter, or as JSON if there is none
+func (c *Context) JSONP(code int, obj interface{}) {
+	c.Render(code, render.JSONP{Callback: c.Query("callback"), Data: obj})
+}
+
+// PureJSON writes obj as JSON without escaping <, > and &
+func (c *Context) PureJSON(code int, obj interface{}) {
+	c.Render(code, render.PureJSON{Data: obj})
+}
+
+func (c *Context) XML(code int, obj interface{}) {
+	c.Render(code, render.XML{Data: obj})
 }
 
 func (c *Context) Data(code int, data []byte) {
-	c.Status(code)
-	c.Writer.Write(data)
+	c.Render(code, render.Data{Data: data})
+}
+
+// DataFromReader copies reader to the response with the given
+// Content-Length and Content-Type, and the extra headers
+func (c *Context) DataFromReader(code int, contentLength int64, contentType string, reader io.Reader, extraHeaders map[string]string) {
+	c.Render(code, render.Reader{
+		ContentType:   contentType,
+		ContentLength: contentLength,
+		Reader:        reader,
+		Headers:       extraHeaders,
+	})
+}
+
+// Redirect redirects the request to location with a 3xx or 201 code
+func (c *Context) Redirect(code int, location string) {
+	c.Render(-1, render.Redirect{Code: code, Request: c.Req, Location: location})
 }
 
 // HTML template render
 // refer https://golang.org/pkg/html/template/
 func (c *Context) HTML(code int, name string, data interface{}) {
-	c.SetHeader("Content-Type", "text/html")
-	c.Status(code)
-	if err := c.engine.htmlTemplates.ExecuteTemplate(c.Writer, name, data); err != nil {
-		c.Fail(500, err.Error())
+	t, err := c.engine.html.lookup(name)
+	if err != nil {
+		c.Fail(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.Render(code, render.HTML{Template: t, Name: name, Data: data})
+}
+
+// Set stores a new key/value pair for this request, e.g. the
+// authenticated user found by a middleware
+func (c *Context) Set(key string, value interface{}) {
+	c.mu.Lock()
+	if c.Keys == nil {
+		c.Keys = make(map[string]interface{})
+	}
+	c.Keys[key] = value
+	c.mu.Unlock()
+}
+
+// Get returns the value for the given key and whether it exists
+func (c *Context) Get(key string) (value interface{}, exists bool) {
+	c.mu.RLock()
+	value, exists = c.Keys[key]
+	c.mu.RUnlock()
+	return
+}
+
+// MustGet returns the value for the given key, it panics if the key does not exist
+func (c *Context) MustGet(key string) interface{} {
+	if value, exists := c.Get(key); exists {
+		return value
+	}
+	panic("gee: key \"" + key + "\" does not exist")
+}
+
+// GetString returns the value associated with the key as a string
+func (c *Context) GetString(key string) (s string) {
+	if v, ok := c.Get(key); ok {
+		s, _ = v.(string)
+	}
+	return
+}
+
+// GetBool returns the value associated with the key as a boolean
+func (c *Context) GetBool(key string) (b bool) {
+	if v, ok := c.Get(key); ok {
+		b, _ = v.(bool)
+	}
+	return
+}
+
+// GetInt returns the value associated with the key as an integer
+func (c *Context) GetInt(key string) (i int) {
+	if v, ok := c.Get(key); ok {
+		i, _ = v.(int)
+	}
+	return
+}
+
+// GetInt64 returns the value associated with the key as an int64
+func (c *Context) GetInt64(key string) (i int64) {
+	if v, ok := c.Get(key); ok {
+		i, _ = v.(int64)
+	}
+	return
+}
+
+// GetFloat64 returns the value associated with the key as a float64
+func (c *Context) GetFloat64(key string) (f float64) {
+	if v, ok := c.Get(key); ok {
+		f, _ = v.(float64)
+	}
+	return
+}
+
+// GetTime returns the value associated with the key as a time.Time
+func (c *Context) GetTime(key string) (t time.Time) {
+	if v, ok := c.Get(key); ok {
+		t, _ = v.(time.Time)
+	}
+	return
+}
+
+// GetDuration returns the value associated with the key as a time.Duration
+func (c *Context) GetDuration(key string) (d time.Duration) {
+	if v, ok := c.Get(key); ok {
+		d, _ = v.(time.Duration)
+	}
+	return
+}
+
+// GetStringSlice returns the value associated with the key as a slice of strings
+func (c *Context) GetStringSlice(key string) (ss []string) {
+	if v, ok := c.Get(key); ok {
+		ss, _ = v.([]string)
+	}
+	return
+}
+
+// GetStringMap returns the value associated with the key as a map
+func (c *Context) GetStringMap(key string) (m map[string]interface{}) {
+	if v, ok := c.Get(key); ok {
+		m, _ = v.(map[string]interface{})
+	}
+	return
+}
+
+// Context implements context.Context, so it can be passed to database
+// and RPC calls directly. Deadline, Done and Err delegate to the request
+// context. A pooled Context must not be used after the handler returns,
+// pass Copy() to goroutines instead.
+
+// Deadline returns the deadline of the request context
+func (c *Context) Deadline() (deadline time.Time, ok bool) {
+	if c.Req == nil {
+		return
+	}
+	return c.Req.Context().Deadline()
+}
+
+// Done returns the channel closed when the request is canceled
+func (c *Context) Done() <-chan struct{} {
+	if c.Req == nil {
+		return nil
+	}
+	return c.Req.Context().Done()
+}
+
+// Err returns why the request context was canceled, if it was
+func (c *Context) Err() error {
+	if c.Req == nil {
+		return nil
+	}
+	return c.Req.Context().Err()
+}
+
+// Value returns the value stored with Set for string keys,
+// and falls back to the request context otherwise
+func (c *Context) Value(key interface{}) interface{} {
+	if s, ok := key.(string); ok {
+		if value, exists := c.Get(s); exists {
+			return value
+		}
+	}
+	if c.Req == nil {
+		return nil
 	}
+	return c.Req.Context().Value(key)
 }
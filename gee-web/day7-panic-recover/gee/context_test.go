@@ -0,0 +1,164 @@
+package gee
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// nopWriter is a reusable http.ResponseWriter for benchmarks
+type nopWriter struct {
+	header http.Header
+}
+
+func (w *nopWriter) Header() http.Header         { return w.header }
+func (w *nopWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *nopWriter) WriteHeader(int)             {}
+
+func TestContextPoolNoLeak(t *testing.T) {
+	r := New()
+	r.Use(func(c *Context) {
+		if c.StatusCode != 0 || c.index != 0 || len(c.handlers) != 2 {
+			t.Errorf("pooled context leaked state: status=%d index=%d handlers=%d",
+				c.StatusCode, c.index, len(c.handlers))
+		}
+		c.Next()
+	})
+	r.GET("/users/:id", func(c *Context) {
+		if len(c.Params) != 1 || c.Param("id") != c.Query("id") {
+			t.Errorf("pooled context leaked params: %v for %s", c.Params, c.Req.URL)
+		}
+		c.String(http.StatusOK, c.Param("id"))
+	})
+	r.GET("/static", func(c *Context) {
+		if len(c.Params) != 0 {
+			t.Errorf("pooled context leaked params: %v for %s", c.Params, c.Req.URL)
+		}
+		c.Status(http.StatusAccepted)
+	})
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				url := "/static"
+				if i%2 == 0 {
+					id := fmt.Sprintf("%d-%d", g, i)
+					url = "/users/" + id + "?id=" + id
+				}
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+				if w.Code != http.StatusOK && w.Code != http.StatusAccepted {
+					t.Errorf("%s: unexpected status %d", url, w.Code)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestContextCopy(t *testing.T) {
+	r := New()
+	done := make(chan string)
+	r.GET("/users/:id", func(c *Context) {
+		cp := c.Copy()
+		go func() {
+			// the original context is back in the pool by now
+			done <- cp.Param("id") + " " + cp.Path
+		}()
+	})
+
+	for _, id := range []string{"1", "2"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/"+id, nil))
+		if got := <-done; got != id+" /users/"+id {
+			t.Fatalf("copy should keep the request data, got %q", got)
+		}
+	}
+}
+
+func benchmarkServeHTTP(b *testing.B, pattern, path string) {
+	r := New()
+	r.Use(func(c *Context) { c.Next() })
+	r.GET(pattern, func(c *Context) {})
+	w := &nopWriter{header: make(http.Header)}
+	req := httptest.NewRequest("GET", path, nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.ServeHTTP(w, req)
+	}
+}
+
+func BenchmarkServeHTTPStatic(b *testing.B) {
+	benchmarkServeHTTP(b, "/users", "/users")
+}
+
+func BenchmarkServeHTTPParam(b *testing.B) {
+	benchmarkServeHTTP(b, "/users/:id/posts/:post", "/users/42/posts/7")
+}
+
+func TestContextKeys(t *testing.T) {
+	r := New()
+	r.Use(func(c *Context) {
+		c.Set("user", "geektutu")
+		c.Set("admin", true)
+		c.Next()
+	})
+	var leaked bool
+	r.GET("/", func(c *Context) {
+		if c.GetString("user") != "geektutu" || !c.GetBool("admin") || c.GetInt("user") != 0 {
+			t.Errorf("keys set by middleware should reach the handler: %v", c.Keys)
+		}
+		if _, exists := c.Get("request"); exists {
+			leaked = true
+		}
+		c.Set("request", 1)
+	})
+	for i := 0; i < 3; i++ {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+	if leaked {
+		t.Fatal("keys leaked between pooled contexts")
+	}
+
+	c := &Context{}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("MustGet should panic on a missing key")
+		}
+	}()
+	c.MustGet("missing")
+}
+
+type ctxKey struct{}
+
+func TestContextAsContext(t *testing.T) {
+	var _ context.Context = (*Context)(nil)
+
+	r := New()
+	r.GET("/", func(c *Context) {
+		c.Set("user", "geektutu")
+		var ctx context.Context = c
+		if ctx.Value("user") != "geektutu" || ctx.Value(ctxKey{}) != "request" {
+			t.Errorf("Value should read the key store, then the request context")
+		}
+		if _, ok := ctx.Deadline(); !ok {
+			t.Errorf("Deadline should come from the request context")
+		}
+		<-ctx.Done()
+		if ctx.Err() != context.Canceled {
+			t.Errorf("Err should be context.Canceled, got %v", ctx.Err())
+		}
+	})
+
+	base, cancel := context.WithTimeout(context.WithValue(context.Background(), ctxKey{}, "request"), time.Hour)
+	req := httptest.NewRequest("GET", "/", nil).WithContext(base)
+	cancel()
+	r.ServeHTTP(httptest.NewRecorder(), req)
+}
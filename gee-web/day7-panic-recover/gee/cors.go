@@ -0,0 +1,179 @@
+package gee
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig configures the CORS middleware
+type CORSConfig struct {
+	// AllowOrigins lists the allowed origins, e.g. "https://example.com".
+	// "*" allows any origin and "https://*.example.com" its subdomains.
+	AllowOrigins []string
+	// AllowOriginFunc allows the origins AllowOrigins doesn't
+	AllowOriginFunc func(origin string) bool
+	// AllowMethods defaults to GET, POST, PUT, PATCH, DELETE and HEAD
+	AllowMethods []string
+	// AllowHeaders are the request headers allowed, "*" allows any.
+	// It defaults to Origin, Content-Length and Content-Type.
+	AllowHeaders []string
+	// ExposeHeaders are the response headers scripts may read
+	ExposeHeaders []string
+	// AllowCredentials allows cookies and HTTP authentication,
+	// it can't be combined with the "*" origin
+	AllowCredentials bool
+	// MaxAge is how long preflight results may be cached
+	MaxAge time.Duration
+}
+
+// wildcardOrigin is an allowed origin such as https://*.example.com
+type wildcardOrigin struct {
+	prefix, suffix string
+}
+
+func (w wildcardOrigin) match(origin string) bool {
+	return len(origin) > len(w.prefix)+len(w.suffix) &&
+		strings.HasPrefix(origin, w.prefix) && strings.HasSuffix(origin, w.suffix)
+}
+
+type cors struct {
+	allowAll         bool
+	origins          map[string]bool
+	wildcards        []wildcardOrigin
+	originFunc       func(string) bool
+	allowMethods     string
+	allowHeaders     string
+	anyHeader        bool
+	exposeHeaders    string
+	allowCredentials bool
+	maxAge           string
+}
+
+// CORS answers the preflight requests of the allowed origins and adds
+// the CORS headers to their requests, other origins get a 403. Use it
+// on the engine, then preflight requests are answered even for paths
+// without an OPTIONS route or without any route.
+func CORS(config CORSConfig) HandlerFunc {
+	cors := &cors{
+		origins:          make(map[string]bool),
+		originFunc:       config.AllowOriginFunc,
+		exposeHeaders:    strings.Join(config.ExposeHeaders, ", "),
+		allowCredentials: config.AllowCredentials,
+	}
+	for _, origin := range config.AllowOrigins {
+		origin = strings.ToLower(origin)
+		switch i := strings.IndexByte(origin, '*'); {
+		case origin == "*":
+			cors.allowAll = true
+		case i >= 0:
+			cors.wildcards = append(cors.wildcards, wildcardOrigin{origin[:i], origin[i+1:]})
+		default:
+			cors.origins[origin] = true
+		}
+	}
+	if cors.allowAll && cors.allowCredentials {
+		// any site could make requests with the cookies of the user
+		panic(`gee: CORS can't allow credentials for the "*" origin`)
+	}
+	methods := config.AllowMethods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodPost, http.MethodPut,
+			http.MethodPatch, http.MethodDelete, http.MethodHead}
+	}
+	cors.allowMethods = strings.ToUpper(strings.Join(methods, ", "))
+	headers := config.AllowHeaders
+	if len(headers) == 0 {
+		headers = []string{"Origin", "Content-Length", "Content-Type"}
+	}
+	for _, h := range headers {
+		if h == "*" {
+			cors.anyHeader = true
+		}
+	}
+	cors.allowHeaders = strings.Join(headers, ", ")
+	if config.MaxAge > 0 {
+		cors.maxAge = strconv.FormatInt(int64(config.MaxAge/time.Second), 10)
+	}
+	return cors.handle
+}
+
+func (cors *cors) allowed(origin string) bool {
+	if cors.allowAll {
+		return true
+	}
+	lower := strings.ToLower(origin)
+	if cors.origins[lower] {
+		return true
+	}
+	for _, w := range cors.wildcards {
+		if w.match(lower) {
+			return true
+		}
+	}
+	return cors.originFunc != nil && cors.originFunc(origin)
+}
+
+func (cors *cors) handle(c *Context) {
+	origin := c.Req.Header.Get("Origin")
+	if origin == "" {
+		c.Next()
+		return
+	}
+	header := c.Writer.Header()
+	preflight := c.Method == http.MethodOptions && c.Req.Header.Get("Access-Control-Request-Method") != ""
+	if preflight {
+		header.Add("Vary", "Origin")
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+	} else if !cors.allowAll {
+		header.Add("Vary", "Origin")
+	}
+
+	if !cors.allowed(origin) {
+		if sameOrigin(origin, c.Req.Host) {
+			c.Next()
+			return
+		}
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+	if cors.allowAll {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if cors.allowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if !preflight {
+		if cors.exposeHeaders != "" {
+			header.Set("Access-Control-Expose-Headers", cors.exposeHeaders)
+		}
+		c.Next()
+		return
+	}
+	header.Set("Access-Control-Allow-Methods", cors.allowMethods)
+	if cors.anyHeader {
+		if requested := c.Req.Header.Get("Access-Control-Request-Headers"); requested != "" {
+			header.Set("Access-Control-Allow-Headers", requested)
+		}
+	} else {
+		header.Set("Access-Control-Allow-Headers", cors.allowHeaders)
+	}
+	if cors.maxAge != "" {
+		header.Set("Access-Control-Max-Age", cors.maxAge)
+	}
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+// sameOrigin reports whether origin is the host the request was sent to,
+// browsers send Origin on same-origin requests too
+func sameOrigin(origin, host string) bool {
+	if i := strings.Index(origin, "://"); i >= 0 {
+		origin = origin[i+3:]
+	}
+	return strings.EqualFold(origin, host)
+}
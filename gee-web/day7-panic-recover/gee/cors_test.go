@@ -0,0 +1,127 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func serveCORS(r *Engine, method, path, origin string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORSOrigins(t *testing.T) {
+	r := New()
+	r.Use(CORS(CORSConfig{
+		AllowOrigins: []string{"https://example.com", "https://*.example.org"},
+		AllowOriginFunc: func(origin string) bool {
+			return strings.HasSuffix(origin, ".test")
+		},
+		ExposeHeaders: []string{"X-Total"},
+	}))
+	r.GET("/items", func(c *Context) {
+		c.String(http.StatusOK, "items")
+	})
+
+	tests := []struct {
+		origin string
+		code   int
+		allow  string
+	}{
+		{"", 200, ""},
+		{"https://example.com", 200, "https://example.com"},
+		{"https://api.example.org", 200, "https://api.example.org"},
+		{"https://example.org", 403, ""},
+		{"http://api.example.org", 403, ""},
+		{"http://local.test", 200, "http://local.test"},
+		{"https://evil.com", 403, ""},
+		{"http://example.com", 200, ""}, // same origin as the request
+	}
+	for _, tt := range tests {
+		w := serveCORS(r, "GET", "http://example.com/items", tt.origin)
+		if w.Code != tt.code || w.Header().Get("Access-Control-Allow-Origin") != tt.allow {
+			t.Fatalf("origin %q answered %d %v", tt.origin, w.Code, w.Header())
+		}
+		if tt.allow != "" && w.Header().Get("Access-Control-Expose-Headers") != "X-Total" {
+			t.Fatalf("origin %q without exposed headers", tt.origin)
+		}
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	r := New()
+	r.Use(CORS(CORSConfig{
+		AllowOrigins:     []string{"https://app.io"},
+		AllowMethods:     []string{"GET", "PUT"},
+		AllowHeaders:     []string{"Authorization", "Content-Type"},
+		AllowCredentials: true,
+		MaxAge:           12 * time.Hour,
+	}))
+	r.PUT("/items/:id", func(c *Context) {
+		c.Status(http.StatusOK)
+	})
+
+	// no OPTIONS route, nor any route at all for /unknown
+	for _, path := range []string{"/items/1", "/unknown"} {
+		w := serveCORS(r, "OPTIONS", path, "https://app.io",
+			"Access-Control-Request-Method", "PUT",
+			"Access-Control-Request-Headers", "Authorization")
+		h := w.Header()
+		if w.Code != http.StatusNoContent ||
+			h.Get("Access-Control-Allow-Origin") != "https://app.io" ||
+			h.Get("Access-Control-Allow-Credentials") != "true" ||
+			h.Get("Access-Control-Allow-Methods") != "GET, PUT" ||
+			h.Get("Access-Control-Allow-Headers") != "Authorization, Content-Type" ||
+			h.Get("Access-Control-Max-Age") != "43200" ||
+			len(h.Values("Vary")) != 3 {
+			t.Fatalf("preflight of %s answered %d %v", path, w.Code, h)
+		}
+	}
+
+	w := serveCORS(r, "PUT", "/items/1", "https://app.io")
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "https://app.io" {
+		t.Fatalf("request answered %d %v", w.Code, w.Header())
+	}
+	// plain OPTIONS requests still get the automatic answer
+	w = serveCORS(r, "OPTIONS", "/items/1", "")
+	if w.Code != http.StatusNoContent || w.Header().Get("Allow") != "OPTIONS, PUT" {
+		t.Fatalf("OPTIONS answered %d %v", w.Code, w.Header())
+	}
+}
+
+func TestCORSAnyOriginWithCredentials(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("CORS should panic on credentials for any origin")
+		}
+	}()
+	CORS(CORSConfig{AllowOrigins: []string{"*"}, AllowCredentials: true})
+}
+
+func TestCORSAnyOrigin(t *testing.T) {
+	r := New()
+	r.Use(CORS(CORSConfig{AllowOrigins: []string{"*"}, AllowHeaders: []string{"*"}}))
+	r.GET("/", func(c *Context) {})
+
+	w := serveCORS(r, "GET", "/", "https://app.io")
+	if w.Header().Get("Access-Control-Allow-Origin") != "*" || w.Header().Get("Vary") != "" {
+		t.Fatalf("request answered %v", w.Header())
+	}
+	w = serveCORS(r, "OPTIONS", "/", "https://app.io",
+		"Access-Control-Request-Method", "GET",
+		"Access-Control-Request-Headers", "X-Custom")
+	if w.Header().Get("Access-Control-Allow-Headers") != "X-Custom" {
+		t.Fatalf("requested headers not allowed: %v", w.Header())
+	}
+}
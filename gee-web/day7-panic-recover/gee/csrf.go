@@ -0,0 +1,195 @@
+package gee
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CSRFTokenKey is the context key the CSRF middleware stores the
+// token for forms and scripts with, see CSRFToken
+const CSRFTokenKey = "csrf_token"
+
+// csrfFieldKey is the context key of the form field name
+const csrfFieldKey = "csrf_field"
+
+// ErrCSRFToken is collected when an unsafe request lacks a valid token
+var ErrCSRFToken = errors.New("gee: invalid CSRF token")
+
+const csrfTokenLength = 32
+
+// CSRFConfig configures the CSRF middleware
+type CSRFConfig struct {
+	// CookieName defaults to "_csrf"
+	CookieName string
+	// HeaderName is the request header carrying the token,
+	// it defaults to "X-CSRF-Token"
+	HeaderName string
+	// FieldName is the form field carrying the token, it defaults to "_csrf"
+	FieldName string
+	// Cookie attributes, the cookie is HttpOnly. MaxAge defaults to 12
+	// hours and SameSite to Lax.
+	Path     string
+	Domain   string
+	MaxAge   time.Duration
+	Secure   bool
+	SameSite http.SameSite
+	// ExemptRoutes are route patterns not checked, e.g. "/hooks/:name"
+	ExemptRoutes []string
+	// Skipper returns true for requests that are not checked
+	Skipper func(*Context) bool
+	// ErrorHandler answers requests with an invalid token after
+	// ErrCSRFToken is collected. It defaults to a plain text 403.
+	ErrorHandler HandlerFunc
+}
+
+// CSRF protects forms and scripts from cross-site request forgery with
+// a double-submit cookie. Every request gets a token, see CSRFToken and
+// CSRFField. Requests other than GET, HEAD, OPTIONS and TRACE must send
+// it back in the form field or the header of the config. ExemptRoutes
+// are compared to Context.FullPath, so they work for routes of any group.
+func CSRF(config CSRFConfig) HandlerFunc {
+	if config.CookieName == "" {
+		config.CookieName = "_csrf"
+	}
+	if config.HeaderName == "" {
+		config.HeaderName = "X-CSRF-Token"
+	}
+	if config.FieldName == "" {
+		config.FieldName = "_csrf"
+	}
+	if config.Path == "" {
+		config.Path = "/"
+	}
+	if config.MaxAge == 0 {
+		config.MaxAge = 12 * time.Hour
+	}
+	if config.SameSite == 0 {
+		config.SameSite = http.SameSiteLaxMode
+	}
+	if config.ErrorHandler == nil {
+		config.ErrorHandler = func(c *Context) {
+			c.String(http.StatusForbidden, "403 FORBIDDEN: invalid CSRF token\n")
+		}
+	}
+	exempt := make(map[string]bool, len(config.ExemptRoutes))
+	for _, pattern := range config.ExemptRoutes {
+		exempt[pattern] = true
+	}
+
+	return func(c *Context) {
+		if exempt[c.FullPath()] || (config.Skipper != nil && config.Skipper(c)) {
+			c.Next()
+			return
+		}
+
+		var token []byte
+		if cookie, err := c.Req.Cookie(config.CookieName); err == nil {
+			token, _ = base64.RawURLEncoding.DecodeString(cookie.Value)
+		}
+		valid := len(token) == csrfTokenLength
+		if !valid {
+			token = make([]byte, csrfTokenLength)
+			if _, err := rand.Read(token); err != nil {
+				c.AbortWithError(http.StatusInternalServerError, err)
+				return
+			}
+			http.SetCookie(c.Writer, &http.Cookie{
+				Name:     config.CookieName,
+				Value:    base64.RawURLEncoding.EncodeToString(token),
+				Path:     config.Path,
+				Domain:   config.Domain,
+				MaxAge:   int(config.MaxAge / time.Second),
+				Secure:   config.Secure,
+				HttpOnly: true,
+				SameSite: config.SameSite,
+			})
+		}
+		addVary(c.Writer.Header(), "Cookie")
+		c.Set(CSRFTokenKey, maskCSRFToken(token))
+		c.Set(csrfFieldKey, config.FieldName)
+
+		switch c.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			c.Next()
+			return
+		}
+		sent := c.Req.Header.Get(config.HeaderName)
+		if sent == "" {
+			// multipart bodies are parsed with the memory limit of the
+			// engine, as ShouldBindForm does
+			err := c.Req.ParseMultipartForm(c.engine.MaxMultipartMemory)
+			if err == nil || err == http.ErrNotMultipart {
+				sent = c.Req.PostForm.Get(config.FieldName)
+			}
+		}
+		if !valid || !csrfTokenEqual(token, sent) {
+			c.Error(ErrCSRFToken)
+			c.Abort()
+			config.ErrorHandler(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// maskCSRFToken returns the token xor a random pad along with the pad,
+// so that it differs on every response and can't be guessed by
+// compression attacks such as BREACH
+func maskCSRFToken(token []byte) string {
+	masked := make([]byte, 2*len(token))
+	pad := masked[:len(token)]
+	if _, err := rand.Read(pad); err != nil {
+		panic(err)
+	}
+	for i, b := range token {
+		masked[len(token)+i] = b ^ pad[i]
+	}
+	return base64.RawURLEncoding.EncodeToString(masked)
+}
+
+// csrfTokenEqual unmasks sent and compares it to token in constant time
+func csrfTokenEqual(token []byte, sent string) bool {
+	masked, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(sent))
+	if err != nil || len(masked) != 2*csrfTokenLength {
+		return false
+	}
+	unmasked := make([]byte, csrfTokenLength)
+	for i := range unmasked {
+		unmasked[i] = masked[i] ^ masked[csrfTokenLength+i]
+	}
+	return subtle.ConstantTimeCompare(unmasked, token) == 1
+}
+
+// CSRFToken returns the token of the request for forms and scripts,
+// e.g. in a meta tag read by the script setting the X-CSRF-Token header
+func CSRFToken(c *Context) string {
+	return c.GetString(CSRFTokenKey)
+}
+
+// CSRFField is the csrfField template func, it returns the hidden input
+// carrying the token of a request. Pass it the *Context, or the token
+// for forms using the default field name:
+//
+//	r.SetFuncMap(template.FuncMap{"csrfField": gee.CSRFField})
+//	c.HTML(http.StatusOK, "form.tmpl", gee.H{"ctx": c})
+//	<form method="post">{{csrfField .ctx}}...</form>
+func CSRFField(v interface{}) template.HTML {
+	name, token := "_csrf", ""
+	switch v := v.(type) {
+	case *Context:
+		token = CSRFToken(v)
+		if field := v.GetString(csrfFieldKey); field != "" {
+			name = field
+		}
+	case string:
+		token = v
+	}
+	return template.HTML(`<input type="hidden" name="` + template.HTMLEscapeString(name) +
+		`" value="` + template.HTMLEscapeString(token) + `">`)
+}
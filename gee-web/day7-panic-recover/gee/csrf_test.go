@@ -0,0 +1,166 @@
+package gee
+
+import (
+	"bytes"
+	"html/template"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func newCSRFEngine(config CSRFConfig) *Engine {
+	r := New()
+	r.Use(CSRF(config))
+	r.SetFuncMap(template.FuncMap{"csrfField": CSRFField})
+	r.LoadHTMLFS(fstest.MapFS{
+		"form.tmpl": {Data: []byte(`<form method="post">{{csrfField .ctx}}</form>`)},
+	}, "*.tmpl")
+	r.GET("/form", func(c *Context) {
+		c.HTML(http.StatusOK, "form.tmpl", H{"ctx": c})
+	})
+	r.POST("/form", func(c *Context) {
+		c.String(http.StatusOK, "saved")
+	})
+	r.POST("/hooks/:name", func(c *Context) {
+		c.String(http.StatusOK, "hooked")
+	})
+	return r
+}
+
+var csrfInput = regexp.MustCompile(`<input type="hidden" name="(\w+)" value="([\w-]+)">`)
+
+// getCSRF renders the form and returns the cookie and the token of its field
+func getCSRF(t *testing.T, r *Engine) (*http.Cookie, string) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/form", nil))
+	m := csrfInput.FindStringSubmatch(w.Body.String())
+	cookies := w.Result().Cookies()
+	if w.Code != http.StatusOK || m == nil || len(cookies) != 1 || !cookies[0].HttpOnly {
+		t.Fatalf("form answered %d %v %q", w.Code, w.Header(), w.Body.String())
+	}
+	return cookies[0], m[2]
+}
+
+func postCSRF(r *Engine, path string, cookie *http.Cookie, form url.Values, header string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	if header != "" {
+		req.Header.Set("X-CSRF-Token", header)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCSRF(t *testing.T) {
+	r := newCSRFEngine(CSRFConfig{ExemptRoutes: []string{"/hooks/:name"}})
+	cookie, token := getCSRF(t, r)
+	_, other := getCSRF(t, r)
+	if token == other {
+		t.Fatal("the masked tokens of two responses are the same")
+	}
+
+	tests := []struct {
+		name   string
+		cookie *http.Cookie
+		form   url.Values
+		header string
+		code   int
+	}{
+		{"form field", cookie, url.Values{"_csrf": {token}}, "", http.StatusOK},
+		{"header", cookie, nil, token, http.StatusOK},
+		{"no token", cookie, nil, "", http.StatusForbidden},
+		{"no cookie", nil, url.Values{"_csrf": {token}}, "", http.StatusForbidden},
+		{"token of another cookie", cookie, url.Values{"_csrf": {other}}, "", http.StatusForbidden},
+		{"garbage", cookie, url.Values{"_csrf": {"abc"}}, "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if w := postCSRF(r, "/form", tt.cookie, tt.form, tt.header); w.Code != tt.code {
+			t.Fatalf("%s answered %d %q", tt.name, w.Code, w.Body.String())
+		}
+	}
+	if w := postCSRF(r, "/hooks/github", nil, nil, ""); w.Code != http.StatusOK {
+		t.Fatalf("exempt route answered %d", w.Code)
+	}
+	// the token is not read from the query
+	req := httptest.NewRequest("POST", "/form?_csrf="+url.QueryEscape(token), nil)
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("token in the query answered %d", w.Code)
+	}
+}
+
+func TestCSRFMultipart(t *testing.T) {
+	r := newCSRFEngine(CSRFConfig{})
+	r.MaxMultipartMemory = 1
+	r.POST("/upload", func(c *Context) {
+		fh, err := c.FormFile("file")
+		if err != nil {
+			c.Fail(http.StatusBadRequest, err.Error())
+			return
+		}
+		f, _ := fh.Open()
+		defer f.Close()
+		// over MaxMultipartMemory the file is stored on disk
+		if _, ok := f.(*os.File); !ok {
+			c.Fail(http.StatusInternalServerError, "the file is kept in memory")
+			return
+		}
+		c.String(http.StatusOK, "uploaded")
+	})
+	cookie, token := getCSRF(t, r)
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("_csrf", token)
+	fw, _ := mw.CreateFormFile("file", "a.txt")
+	fw.Write([]byte("hello gee"))
+	mw.Close()
+	req := httptest.NewRequest("POST", "/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("multipart form answered %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestCSRFConfig(t *testing.T) {
+	r := newCSRFEngine(CSRFConfig{
+		FieldName: "token",
+		Skipper: func(c *Context) bool {
+			return c.Req.Header.Get("X-API-Key") != ""
+		},
+		ErrorHandler: func(c *Context) {
+			c.JSON(http.StatusBadRequest, H{"message": c.Errors.Last().Error()})
+		},
+	})
+	cookie, token := getCSRF(t, r)
+	if w := postCSRF(r, "/form", cookie, url.Values{"token": {token}}, ""); w.Code != http.StatusOK {
+		t.Fatalf("custom field answered %d", w.Code)
+	}
+	w := postCSRF(r, "/form", cookie, nil, "")
+	if w.Code != http.StatusBadRequest || w.Body.String() != `{"message":"gee: invalid CSRF token"}`+"\n" {
+		t.Fatalf("custom error handler answered %d %q", w.Code, w.Body.String())
+	}
+	req := httptest.NewRequest("POST", "/form", nil)
+	req.Header.Set("X-API-Key", "key")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("skipped request answered %d", w.Code)
+	}
+}
@@ -0,0 +1,128 @@
+package gee
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ErrorType classifies the errors collected by Context.Error
+type ErrorType uint8
+
+const (
+	// ErrorTypePrivate errors are only logged, never shown to clients
+	ErrorTypePrivate ErrorType = 1 << iota
+	// ErrorTypePublic errors are safe to show to clients
+	ErrorTypePublic
+	// ErrorTypeBind errors are returned by Context.Bind
+	ErrorTypeBind
+	// ErrorTypeAny matches every type in Errors.ByType
+	ErrorTypeAny ErrorType = 1<<8 - 1
+)
+
+// Error is an error collected on the Context during the handler chain
+type Error struct {
+	Err  error
+	Type ErrorType
+	Meta interface{} // extra data rendered with public errors
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error, for errors.Is and errors.As
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// SetType sets the type of the error
+func (e *Error) SetType(t ErrorType) *Error {
+	e.Type = t
+	return e
+}
+
+// SetMeta sets the extra data of the error
+func (e *Error) SetMeta(meta interface{}) *Error {
+	e.Meta = meta
+	return e
+}
+
+// IsType reports whether the error has one of the types in t
+func (e *Error) IsType(t ErrorType) bool {
+	return e.Type&t > 0
+}
+
+// MarshalJSON renders the message, and the meta data if any
+func (e *Error) MarshalJSON() ([]byte, error) {
+	obj := H{"error": e.Error()}
+	if e.Meta != nil {
+		obj["meta"] = e.Meta
+	}
+	return json.Marshal(obj)
+}
+
+// Errors are the errors collected on a Context, in order
+type Errors []*Error
+
+// ByType returns the errors that have one of the types in t
+func (errs Errors) ByType(t ErrorType) Errors {
+	var result Errors
+	for _, e := range errs {
+		if e.IsType(t) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// Last returns the last error, or nil if there is none
+func (errs Errors) Last() *Error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs[len(errs)-1]
+}
+
+func (errs Errors) String() string {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Error attaches err to the current context, to be rendered by the error
+// handler of the engine once the chain finishes. Errors that are not
+// already an *Error are collected as ErrorTypePrivate.
+func (c *Context) Error(err error) *Error {
+	if err == nil {
+		panic("gee: err is nil")
+	}
+	var e *Error
+	if !errors.As(err, &e) {
+		e = &Error{Err: err, Type: ErrorTypePrivate}
+	}
+	c.Errors = append(c.Errors, e)
+	return e
+}
+
+// defaultErrorHandler renders the public and bind errors as JSON with
+// the status of the context, unless a response has been written already.
+// Private errors only show up as the status text.
+func defaultErrorHandler(c *Context) {
+	if c.Writer.Written() {
+		return
+	}
+	code := c.StatusCode
+	if code == 0 {
+		code = http.StatusInternalServerError
+	}
+	obj := H{"message": http.StatusText(code)}
+	if errs := c.Errors.ByType(ErrorTypePublic | ErrorTypeBind); len(errs) > 0 {
+		obj["message"] = errs.Last().Error()
+		obj["errors"] = errs
+	}
+	c.JSON(code, obj)
+}
@@ -0,0 +1,121 @@
+package gee
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAbort(t *testing.T) {
+	r := New()
+	var reached bool
+	auth := func(c *Context) {
+		if c.Query("token") == "" {
+			c.AbortWithError(http.StatusUnauthorized, errors.New("missing token")).SetType(ErrorTypePublic)
+			return
+		}
+		c.Next()
+	}
+	r.Use(func(c *Context) {
+		c.Next()
+		if c.Query("token") == "" && !c.IsAborted() {
+			t.Error("IsAborted should be true after Abort")
+		}
+	})
+	r.GET("/admin", auth, func(c *Context) {
+		reached = true
+		c.String(http.StatusOK, "ok")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/admin", nil))
+	if reached {
+		t.Fatal("handler should not run after Abort")
+	}
+	if w.Code != http.StatusUnauthorized || w.Body.String() != `{"errors":[{"error":"missing token"}],"message":"missing token"}`+"\n" {
+		t.Fatalf("unexpected response %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/admin?token=1", nil))
+	if !reached || w.Code != http.StatusOK {
+		t.Fatal("handler should run with a token")
+	}
+}
+
+func TestAbortWithStatus(t *testing.T) {
+	r := New()
+	r.GET("/status", func(c *Context) {
+		c.AbortWithStatus(http.StatusForbidden)
+	}, func(c *Context) {
+		t.Error("handler should not run after AbortWithStatus")
+	})
+	r.GET("/json", func(c *Context) {
+		c.AbortWithStatusJSON(http.StatusTeapot, H{"tea": true})
+	}, func(c *Context) {
+		t.Error("handler should not run after AbortWithStatusJSON")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/status", nil))
+	if w.Code != http.StatusForbidden || w.Body.Len() != 0 {
+		t.Fatalf("unexpected response %d %s", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/json", nil))
+	if w.Code != http.StatusTeapot || w.Body.String() != `{"tea":true}`+"\n" {
+		t.Fatalf("unexpected response %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	r := New()
+	r.GET("/private", func(c *Context) {
+		c.Error(errors.New("db is down"))
+	})
+	r.GET("/written", func(c *Context) {
+		c.String(http.StatusOK, "partial")
+		c.Error(errors.New("late")).SetType(ErrorTypePublic)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/private", nil))
+	if w.Code != http.StatusInternalServerError || w.Body.String() != `{"message":"Internal Server Error"}`+"\n" {
+		t.Fatalf("private errors should not be shown, got %d %s", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/written", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "partial" {
+		t.Fatalf("written responses should be left alone, got %d %s", w.Code, w.Body.String())
+	}
+
+	var collected Errors
+	r.SetErrorHandler(func(c *Context) {
+		collected = c.Errors.ByType(ErrorTypeAny)
+		c.String(http.StatusServiceUnavailable, "custom")
+	})
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/private", nil))
+	if w.Code != http.StatusServiceUnavailable || len(collected) != 1 || collected.Last().Error() != "db is down" {
+		t.Fatalf("custom error handler should render, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestBindErrorResponse(t *testing.T) {
+	w := serveBind("POST", "/users/1", "application/json", `{"role":"guest"}`, func(c *Context) {
+		c.Bind(&bindUser{})
+	})
+	var body struct {
+		Errors []struct {
+			Meta []FieldError `json:"meta"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusBadRequest || len(body.Errors) != 1 || len(body.Errors[0].Meta) != 2 {
+		t.Fatalf("bind errors should be rendered as 400 with field errors, got %d %s", w.Code, w.Body.String())
+	}
+}
@@ -1,13 +1,21 @@
 package gee
 
 import (
-	"html/template"
 	"log"
 	"net/http"
-	"path"
-	"strings"
+	"sync"
+	"time"
+
+	"gee/websocket"
 )
 
+// anyMethods are the methods registered by RouterGroup.Any
+var anyMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodHead, http.MethodOptions, http.MethodDelete,
+	http.MethodConnect, http.MethodTrace,
+}
+
 // HandlerFunc defines the request handler used by gee
 type HandlerFunc func(*Context)
 
@@ -22,18 +30,60 @@ type (
 
 	Engine struct {
 		*RouterGroup
-		router        *router
-		groups        []*RouterGroup     // store all groups
-		htmlTemplates *template.Template // for html render
-		funcMap       template.FuncMap   // for html render
+		router       *router
+		groups       []*RouterGroup // store all groups
+		routes       []*route       // store all routes, to rebuild their handlers
+		html         htmlRender     // for html render
+		pool         sync.Pool      // reuse Contexts across requests
+		errorHandler HandlerFunc    // renders the errors collected on a Context
+		// handlers for unmatched requests, and with the global middlewares
+		noRoute, noMethod       []HandlerFunc
+		allNoRoute, allNoMethod []HandlerFunc
+		allOptions              []HandlerFunc
+		// ShutdownTimeout bounds how long Shutdown waits for in-flight
+		// requests when its context has no deadline, 0 waits forever
+		ShutdownTimeout time.Duration
+		lifecycle       lifecycle // servers started by the Run methods
+		// WebSocket upgrades the connections of RouterGroup.WS
+		WebSocket websocket.Upgrader
+		// MaxMultipartMemory is the memory used to parse multipart forms,
+		// larger files are stored in temporary files
+		MaxMultipartMemory int64
+		// MaxRequestBodySize caps request bodies, larger ones are answered
+		// with 413. 0 means no limit.
+		MaxRequestBodySize int64
+		// SecureJSONPrefix is written before the arrays of Context.SecureJSON
+		SecureJSONPrefix string
+		// ForwardedByClientIP makes Context.ClientIP trust the
+		// X-Forwarded-For and X-Real-IP headers set by a proxy
+		ForwardedByClientIP bool
 	}
 )
 
+// route remembers where a route was registered, so that its handler
+// chain can be rebuilt when middleware is added to an ancestor group
+type route struct {
+	group    *RouterGroup
+	handlers []HandlerFunc // route specific handlers
+	node     *node
+}
+
 // New is the constructor of gee.Engine
 func New() *Engine {
-	engine := &Engine{router: newRouter()}
+	engine := &Engine{
+		router:             newRouter(),
+		errorHandler:       defaultErrorHandler,
+		ShutdownTimeout:    defaultShutdownTimeout,
+		MaxMultipartMemory: defaultMultipartMemory,
+		SecureJSONPrefix:   "while(1);",
+	}
+	engine.lifecycle.stopped = make(chan struct{})
 	engine.RouterGroup = &RouterGroup{engine: engine}
 	engine.groups = []*RouterGroup{engine.RouterGroup}
+	engine.pool.New = func() interface{} {
+		return engine.allocateContext()
+	}
+	engine.rebuildHandlers()
 	return engine
 }
 
@@ -57,74 +107,144 @@ func (group *RouterGroup) Group(prefix string) *RouterGroup {
 	return newGroup
 }
 
-// Use is defined to add middleware to the group
+// Use is defined to add middleware to the group,
+// routes registered before are updated as well
 func (group *RouterGroup) Use(middlewares ...HandlerFunc) {
 	group.middlewares = append(group.middlewares, middlewares...)
+	group.engine.rebuildHandlers()
+}
+
+// rebuildHandlers resolves the handler chains again
+// after middlewares have been added to a group
+func (engine *Engine) rebuildHandlers() {
+	for _, r := range engine.routes {
+		r.node.handlers = r.group.combineHandlers(r.handlers)
+	}
+	noRoute, noMethod := engine.noRoute, engine.noMethod
+	if len(noRoute) == 0 {
+		noRoute = []HandlerFunc{defaultNoRoute}
+	}
+	if len(noMethod) == 0 {
+		noMethod = []HandlerFunc{defaultNoMethod}
+	}
+	engine.allNoRoute = engine.combineHandlers(noRoute)
+	engine.allNoMethod = engine.combineHandlers(noMethod)
+	engine.allOptions = engine.combineHandlers([]HandlerFunc{defaultOptions})
+}
+
+// NoRoute sets the handlers for requests that match no route, e.g. to
+// render a JSON 404 or serve index.html of a single page app. They run
+// behind the global middlewares, the default is a plain text 404.
+func (engine *Engine) NoRoute(handlers ...HandlerFunc) {
+	engine.noRoute = handlers
+	engine.rebuildHandlers()
+}
+
+// NoMethod sets the handlers for requests whose path only matches routes
+// of other methods. They run behind the global middlewares with the Allow
+// header already set, the default is a plain text 405.
+func (engine *Engine) NoMethod(handlers ...HandlerFunc) {
+	engine.noMethod = handlers
+	engine.rebuildHandlers()
+}
+
+// combineHandlers returns the middlewares of all ancestors
+// of the group, then its own, followed by handlers
+func (group *RouterGroup) combineHandlers(handlers []HandlerFunc) []HandlerFunc {
+	var groups []*RouterGroup
+	for g := group; g != nil; g = g.parent {
+		groups = append(groups, g)
+	}
+	size := len(handlers)
+	for _, g := range groups {
+		size += len(g.middlewares)
+	}
+	merged := make([]HandlerFunc, 0, size)
+	for i := len(groups) - 1; i >= 0; i-- {
+		merged = append(merged, groups[i].middlewares...)
+	}
+	return append(merged, handlers...)
 }
 
-func (group *RouterGroup) addRoute(method string, comp string, handler HandlerFunc) {
+// addRoute registers handlers for the pattern, the last one is the route
+// handler and the ones before are route specific middlewares. The complete
+// chain is resolved here once instead of on every request.
+func (group *RouterGroup) addRoute(method string, comp string, handlers []HandlerFunc) {
 	pattern := group.prefix + comp
 	log.Printf("Route %4s - %s", method, pattern)
-	group.engine.router.addRoute(method, pattern, handler)
+	engine := group.engine
+	n, err := engine.router.addRoute(method, pattern, group.combineHandlers(handlers))
+	if err != nil {
+		panic(err)
+	}
+	engine.routes = append(engine.routes, &route{group: group, handlers: handlers, node: n})
 }
 
 // GET defines the method to add GET request
-func (group *RouterGroup) GET(pattern string, handler HandlerFunc) {
-	group.addRoute("GET", pattern, handler)
+func (group *RouterGroup) GET(pattern string, handlers ...HandlerFunc) {
+	group.addRoute("GET", pattern, handlers)
 }
 
 // POST defines the method to add POST request
-func (group *RouterGroup) POST(pattern string, handler HandlerFunc) {
-	group.addRoute("POST", pattern, handler)
+func (group *RouterGroup) POST(pattern string, handlers ...HandlerFunc) {
+	group.addRoute("POST", pattern, handlers)
 }
 
-// create static handler
-func (group *RouterGroup) createStaticHandler(relativePath string, fs http.FileSystem) HandlerFunc {
-	absolutePath := path.Join(group.prefix, relativePath)
-	fileServer := http.StripPrefix(absolutePath, http.FileServer(fs))
-	return func(c *Context) {
-		file := c.Param("filepath")
-		// Check if file exists and/or if we have permission to access it
-		if _, err := fs.Open(file); err != nil {
-			c.Status(http.StatusNotFound)
-			return
-		}
+// PUT defines the method to add PUT request
+func (group *RouterGroup) PUT(pattern string, handlers ...HandlerFunc) {
+	group.addRoute("PUT", pattern, handlers)
+}
 
-		fileServer.ServeHTTP(c.Writer, c.Req)
-	}
+// DELETE defines the method to add DELETE request
+func (group *RouterGroup) DELETE(pattern string, handlers ...HandlerFunc) {
+	group.addRoute("DELETE", pattern, handlers)
 }
 
-// serve static files
-func (group *RouterGroup) Static(relativePath string, root string) {
-	handler := group.createStaticHandler(relativePath, http.Dir(root))
-	urlPattern := path.Join(relativePath, "/*filepath")
-	// Register GET handlers
-	group.GET(urlPattern, handler)
+// PATCH defines the method to add PATCH request
+func (group *RouterGroup) PATCH(pattern string, handlers ...HandlerFunc) {
+	group.addRoute("PATCH", pattern, handlers)
 }
 
-// for custom render function
-func (engine *Engine) SetFuncMap(funcMap template.FuncMap) {
-	engine.funcMap = funcMap
+// HEAD defines the method to add HEAD request
+func (group *RouterGroup) HEAD(pattern string, handlers ...HandlerFunc) {
+	group.addRoute("HEAD", pattern, handlers)
 }
 
-func (engine *Engine) LoadHTMLGlob(pattern string) {
-	engine.htmlTemplates = template.Must(template.New("").Funcs(engine.funcMap).ParseGlob(pattern))
+// OPTIONS defines the method to add OPTIONS request,
+// it replaces the automatic OPTIONS response for the pattern
+func (group *RouterGroup) OPTIONS(pattern string, handlers ...HandlerFunc) {
+	group.addRoute("OPTIONS", pattern, handlers)
 }
 
-// Run defines the method to start a http server
-func (engine *Engine) Run(addr string) (err error) {
-	return http.ListenAndServe(addr, engine)
+// Any registers the handlers for all HTTP methods
+func (group *RouterGroup) Any(pattern string, handlers ...HandlerFunc) {
+	for _, method := range anyMethods {
+		group.addRoute(method, pattern, handlers)
+	}
+}
+
+// SetErrorHandler sets the handler that renders the errors collected
+// with Context.Error, it runs after the handler chain finishes
+func (engine *Engine) SetErrorHandler(handler HandlerFunc) {
+	engine.errorHandler = handler
+}
+
+func (engine *Engine) allocateContext() *Context {
+	return &Context{
+		Params: make(Params, 0, engine.router.maxParams),
+		engine: engine,
+	}
 }
 
 func (engine *Engine) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	var middlewares []HandlerFunc
-	for _, group := range engine.groups {
-		if strings.HasPrefix(req.URL.Path, group.prefix) {
-			middlewares = append(middlewares, group.middlewares...)
-		}
-	}
-	c := newContext(w, req)
-	c.handlers = middlewares
-	c.engine = engine
-	engine.router.handle(c)
+	c := engine.pool.Get().(*Context)
+	c.reset(w, req)
+	if engine.limitBody(c) {
+		engine.router.handle(c)
+	}
+	if len(c.Errors) > 0 {
+		engine.errorHandler(c)
+	}
+	c.Writer.WriteHeaderNow()
+	c.release()
 }
@@ -1,6 +1,11 @@
 package gee
 
-import "testing"
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
 
 func TestNestedGroup(t *testing.T) {
 	r := New()
@@ -14,3 +19,137 @@ func TestNestedGroup(t *testing.T) {
 		t.Fatal("v2 prefix should be /v1/v2")
 	}
 }
+
+func TestMethodNotAllowed(t *testing.T) {
+	r := New()
+	r.GET("/users/:id", func(c *Context) {})
+	r.PUT("/users/:id", func(c *Context) {})
+	r.DELETE("/users/:id", func(c *Context) {})
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+		allow  string
+	}{
+		{"GET", "/users/1", http.StatusOK, ""},
+		{"POST", "/users/1", http.StatusMethodNotAllowed, "DELETE, GET, OPTIONS, PUT"},
+		{"OPTIONS", "/users/1", http.StatusNoContent, "DELETE, GET, OPTIONS, PUT"},
+		{"OPTIONS", "*", http.StatusNoContent, "DELETE, GET, OPTIONS, PUT"},
+		{"POST", "/posts/1", http.StatusNotFound, ""},
+		{"OPTIONS", "/posts/1", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(tt.method, "/", nil)
+		req.URL.Path = tt.path
+		r.ServeHTTP(w, req)
+		if w.Code != tt.code {
+			t.Fatalf("%s %s: status should be %d, got %d", tt.method, tt.path, tt.code, w.Code)
+		}
+		if allow := w.Header().Get("Allow"); allow != tt.allow {
+			t.Fatalf("%s %s: Allow should be %q, got %q", tt.method, tt.path, tt.allow, allow)
+		}
+	}
+}
+
+func TestOptionsRoute(t *testing.T) {
+	r := New()
+	r.GET("/ping", func(c *Context) {})
+	r.OPTIONS("/ping", func(c *Context) {
+		c.String(http.StatusOK, "custom")
+	})
+	r.Any("/any", func(c *Context) {
+		c.String(http.StatusOK, c.Method)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("OPTIONS", "/ping", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "custom" {
+		t.Fatal("registered OPTIONS route should take precedence")
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/ping", nil))
+	if w.Header().Get("Allow") != "GET, OPTIONS" {
+		t.Fatalf("Allow should list OPTIONS once, got %q", w.Header().Get("Allow"))
+	}
+
+	for _, method := range anyMethods {
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, "/any", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Any should register %s", method)
+		}
+	}
+}
+
+func TestRouteMiddleware(t *testing.T) {
+	trace := func(name string) HandlerFunc {
+		return func(c *Context) {
+			c.Writer.Header().Add("X-Trace", name)
+			c.Next()
+		}
+	}
+	r := New()
+	v1 := r.Group("/v1")
+	v1.Use(trace("v1"))
+	v1.GET("/users", trace("route"), func(c *Context) {})
+	r.GET("/v10/users", func(c *Context) {})
+	// added after the routes were registered
+	r.Use(trace("global"))
+	v1.Use(trace("v1-late"))
+
+	tests := []struct {
+		path  string
+		trace []string
+	}{
+		{"/v1/users", []string{"global", "v1", "v1-late", "route"}},
+		{"/v10/users", []string{"global"}},
+		{"/v1/missing", []string{"global"}},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+		if got := w.Header()["X-Trace"]; !reflect.DeepEqual(got, tt.trace) {
+			t.Fatalf("%s: middlewares should be %v, got %v", tt.path, tt.trace, got)
+		}
+	}
+}
+
+func TestNoRouteNoMethod(t *testing.T) {
+	r := New()
+	r.GET("/users", func(c *Context) {})
+	r.NoRoute(func(c *Context) {
+		c.JSON(http.StatusNotFound, H{"path": c.Path})
+	})
+	r.NoMethod(func(c *Context) {
+		c.JSON(http.StatusMethodNotAllowed, H{"allow": c.Writer.Header().Get("Allow")})
+	})
+	// global middlewares added later still run for unmatched requests
+	r.Use(func(c *Context) {
+		c.SetHeader("Access-Control-Allow-Origin", "*")
+		c.Next()
+	})
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+		body   string
+	}{
+		{"GET", "/missing", http.StatusNotFound, `{"path":"/missing"}` + "\n"},
+		{"POST", "/users", http.StatusMethodNotAllowed, `{"allow":"GET, OPTIONS"}` + "\n"},
+		{"OPTIONS", "/users", http.StatusNoContent, ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.code || w.Body.String() != tt.body {
+			t.Fatalf("%s %s: unexpected response %d %q", tt.method, tt.path, w.Code, w.Body.String())
+		}
+		if w.Header().Get("Access-Control-Allow-Origin") != "*" {
+			t.Fatalf("%s %s: global middlewares should run", tt.method, tt.path)
+		}
+	}
+}
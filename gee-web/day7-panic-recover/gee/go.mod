@@ -1,3 +1,3 @@
 module gee
 
-go 1.13
+go 1.16
@@ -0,0 +1,337 @@
+package gee
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// GzipOptions configures the Gzip middleware
+type GzipOptions struct {
+	// MinLength is the body size below which responses are sent
+	// uncompressed, it defaults to 1024 bytes
+	MinLength int
+	// ExcludedPaths are path prefixes whose responses are not compressed
+	ExcludedPaths []string
+	// ExcludedContentTypes are content types, or prefixes such as
+	// "image/", not compressed in addition to the already compressed
+	// formats skipped by default
+	ExcludedContentTypes []string
+	// MaxDecompressedSize caps the decompressed size of gzip request
+	// bodies, larger ones fail with ErrBodyTooLarge. It defaults to
+	// 10 MB, < 0 means no limit. A smaller MaxRequestBodySize of the
+	// engine applies as well.
+	MaxDecompressedSize int64
+}
+
+// compressedContentTypes are formats compressing again doesn't shrink
+var compressedContentTypes = []string{
+	"image/png", "image/jpeg", "image/gif", "image/webp", "image/avif",
+	"video/", "audio/", "font/woff", "font/woff2",
+	"application/zip", "application/gzip", "application/x-gzip",
+	"application/x-bzip2", "application/x-xz", "application/x-7z-compressed",
+	"application/x-rar-compressed", "application/zstd", "application/pdf",
+}
+
+const (
+	defaultGzipMinLength           = 1024
+	defaultGzipMaxDecompressedSize = 10 << 20 // 10 MB
+)
+
+// compressor is implemented by gzip.Writer and flate.Writer
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// gzipMiddleware holds the encoder pools of a Gzip middleware
+type gzipMiddleware struct {
+	options     GzipOptions
+	excluded    []string
+	gzipPool    sync.Pool
+	deflatePool sync.Pool
+}
+
+// Gzip compresses the responses of clients accepting gzip or deflate with
+// the level of compress/gzip, e.g. gzip.DefaultCompression. Bodies shorter
+// than MinLength and compressed formats are sent as they are, streamed
+// responses are compressed and flushed on every Flush. Request bodies with
+// Content-Encoding gzip are decompressed for the handlers.
+func Gzip(level int, options GzipOptions) HandlerFunc {
+	if _, err := gzip.NewWriterLevel(io.Discard, level); err != nil {
+		panic(err)
+	}
+	if options.MinLength <= 0 {
+		options.MinLength = defaultGzipMinLength
+	}
+	if options.MaxDecompressedSize == 0 {
+		options.MaxDecompressedSize = defaultGzipMaxDecompressedSize
+	}
+	g := &gzipMiddleware{
+		options:  options,
+		excluded: append(append([]string(nil), compressedContentTypes...), options.ExcludedContentTypes...),
+	}
+	g.gzipPool.New = func() interface{} {
+		w, _ := gzip.NewWriterLevel(io.Discard, level)
+		return w
+	}
+	g.deflatePool.New = func() interface{} {
+		w, _ := flate.NewWriter(io.Discard, level)
+		return w
+	}
+	return g.handle
+}
+
+func (g *gzipMiddleware) handle(c *Context) {
+	if c.Req.Header.Get("Content-Encoding") == "gzip" && !g.decompressBody(c) {
+		return
+	}
+	for _, prefix := range g.options.ExcludedPaths {
+		if strings.HasPrefix(c.Path, prefix) {
+			c.Next()
+			return
+		}
+	}
+	addVary(c.Writer.Header(), "Accept-Encoding")
+	encoding := acceptedEncoding(c.Req, "gzip", "deflate")
+	if encoding == "" || c.Method == http.MethodHead || c.Req.Header.Get("Upgrade") != "" {
+		c.Next()
+		return
+	}
+
+	w := &gzipWriter{ResponseWriter: c.Writer, g: g, encoding: encoding}
+	c.Writer = w
+	defer func() {
+		w.close()
+		c.Writer = w.ResponseWriter
+	}()
+	c.Next()
+}
+
+// decompressBody replaces a gzip request body by its content,
+// requests with an invalid body are answered with 400
+func (g *gzipMiddleware) decompressBody(c *Context) bool {
+	body := c.Req.Body
+	if body == nil || body == http.NoBody {
+		return true
+	}
+	zr, err := gzip.NewReader(body)
+	if err != nil {
+		code := http.StatusBadRequest
+		if errors.Is(err, ErrBodyTooLarge) {
+			code = http.StatusRequestEntityTooLarge
+		}
+		c.AbortWithError(code, err)
+		return false
+	}
+	var r io.ReadCloser = gzipBody{zr, body}
+	// a small body may inflate to any size
+	max := g.options.MaxDecompressedSize
+	if limit := c.engine.MaxRequestBodySize; limit > 0 && (max < 0 || limit < max) {
+		max = limit
+	}
+	if max > 0 {
+		r = &maxBytesReader{ReadCloser: r, n: max}
+	}
+	c.Req.Body = r
+	c.Req.Header.Del("Content-Encoding")
+	c.Req.Header.Del("Content-Length")
+	c.Req.ContentLength = -1
+	return true
+}
+
+// gzipBody closes the compressed body along with the reader
+type gzipBody struct {
+	*gzip.Reader
+	body io.Closer
+}
+
+func (b gzipBody) Close() error {
+	b.Reader.Close()
+	return b.body.Close()
+}
+
+// gzipWriter buffers the body until it is longer than MinLength
+// or flushed, then decides whether to compress it
+type gzipWriter struct {
+	ResponseWriter
+	g        *gzipMiddleware
+	encoding string
+	buf      []byte
+	decided  bool
+	enc      compressor // nil if the body is sent uncompressed
+}
+
+var _ ResponseWriter = &gzipWriter{}
+
+func (w *gzipWriter) Write(data []byte) (int, error) {
+	if w.decided {
+		return w.write(data)
+	}
+	w.buf = append(w.buf, data...)
+	if len(w.buf) >= w.g.options.MinLength {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *gzipWriter) write(data []byte) (int, error) {
+	if w.enc != nil {
+		return w.enc.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// decide compresses the body if compress is true and the response allows
+// it, then writes the buffered data
+func (w *gzipWriter) decide(compress bool) error {
+	w.decided = true
+	header := w.Header()
+	if header.Get("Content-Type") == "" && len(w.buf) > 0 {
+		header.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	if compress && w.compressible() {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		// the compressed body is another representation, a strong
+		// ETag would claim it is byte for byte the identity one
+		if tag := header.Get("ETag"); tag != "" && !strings.HasPrefix(tag, "W/") {
+			header.Set("ETag", "W/"+tag)
+		}
+		if w.encoding == "gzip" {
+			w.enc = w.g.gzipPool.Get().(*gzip.Writer)
+		} else {
+			w.enc = w.g.deflatePool.Get().(*flate.Writer)
+		}
+		w.enc.Reset(w.ResponseWriter)
+	}
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := w.write(buf)
+	return err
+}
+
+// compressible reports whether the response may be compressed
+func (w *gzipWriter) compressible() bool {
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" ||
+		!bodyAllowedForStatus(w.Status()) {
+		return false
+	}
+	ctype := strings.ToLower(header.Get("Content-Type"))
+	if i := strings.IndexByte(ctype, ';'); i >= 0 {
+		ctype = ctype[:i]
+	}
+	for _, excluded := range w.g.excluded {
+		if strings.HasPrefix(ctype, excluded) {
+			return false
+		}
+	}
+	return true
+}
+
+// Flush compresses streamed responses regardless of MinLength
+func (w *gzipWriter) Flush() {
+	if !w.decided {
+		w.decide(true)
+	}
+	if w.enc != nil {
+		w.enc.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// WriteHeaderNow sends the buffered body uncompressed first if it is
+// short, as the headers can't change afterwards
+func (w *gzipWriter) WriteHeaderNow() {
+	if !w.decided {
+		w.decide(len(w.buf) >= w.g.options.MinLength)
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+// Written returns true once the body is buffered, the headers
+// can't be relied on to change the response anymore
+func (w *gzipWriter) Written() bool {
+	return len(w.buf) > 0 || w.ResponseWriter.Written()
+}
+
+// close sends the short bodies uncompressed and finishes the
+// compressed ones, returning the encoder to its pool
+func (w *gzipWriter) close() {
+	if !w.decided {
+		if len(w.buf) == 0 {
+			return
+		}
+		w.decide(false)
+	}
+	if w.enc == nil {
+		return
+	}
+	w.enc.Close()
+	w.enc.Reset(io.Discard)
+	if w.encoding == "gzip" {
+		w.g.gzipPool.Put(w.enc)
+	} else {
+		w.g.deflatePool.Put(w.enc)
+	}
+	w.enc = nil
+}
+
+// addVary adds value to the Vary header unless it is there already
+func addVary(header http.Header, value string) {
+	for _, v := range header.Values("Vary") {
+		for _, field := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), value) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", value)
+}
+
+// acceptedEncoding returns the first of the encodings the Accept-Encoding
+// header of req accepts with the highest q-value, or "" if there is none
+func acceptedEncoding(req *http.Request, encodings ...string) string {
+	accept := req.Header.Get("Accept-Encoding")
+	if accept == "" {
+		return ""
+	}
+	qs := make(map[string]float64)
+	for _, v := range strings.Split(accept, ",") {
+		coding, q := strings.TrimSpace(v), 1.0
+		if i := strings.IndexByte(coding, ';'); i >= 0 {
+			param := strings.TrimSpace(coding[i+1:])
+			coding = strings.TrimSpace(coding[:i])
+			if strings.HasPrefix(param, "q=") {
+				var err error
+				if q, err = strconv.ParseFloat(param[2:], 64); err != nil {
+					q = 0
+				}
+			}
+		}
+		qs[strings.ToLower(coding)] = q
+	}
+	best, bestQ := "", 0.0
+	for _, encoding := range encodings {
+		q, ok := qs[encoding]
+		if !ok {
+			q = qs["*"]
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,225 @@
+package gee
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gee/render"
+)
+
+func newGzipEngine() *Engine {
+	r := New()
+	r.Use(Gzip(gzip.DefaultCompression, GzipOptions{ExcludedPaths: []string{"/raw"}}))
+	long := strings.Repeat("gee ", 1000)
+	r.GET("/long", func(c *Context) {
+		c.String(http.StatusOK, long)
+	})
+	r.GET("/raw/long", func(c *Context) {
+		c.String(http.StatusOK, long)
+	})
+	r.GET("/short", func(c *Context) {
+		c.JSON(http.StatusOK, H{"a": 1})
+	})
+	r.GET("/png", func(c *Context) {
+		c.Render(http.StatusOK, render.Data{ContentType: "image/png", Data: []byte(long)})
+	})
+	r.GET("/empty", func(c *Context) {
+		c.AbortWithStatus(http.StatusNoContent)
+	})
+	r.GET("/stream", func(c *Context) {
+		c.SSEvent("tick", "1")
+		c.SSEvent("tick", "2")
+	})
+	r.POST("/echo", func(c *Context) {
+		b, err := ioutil.ReadAll(c.Req.Body)
+		if err != nil {
+			c.AbortWithError(http.StatusRequestEntityTooLarge, err)
+			return
+		}
+		c.Data(http.StatusOK, b)
+	})
+	return r
+}
+
+func serveGzip(r *Engine, method, path, acceptEncoding string, body io.Reader) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, body)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func gunzip(t *testing.T, b []byte) string {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestGzip(t *testing.T) {
+	r := newGzipEngine()
+	long := strings.Repeat("gee ", 1000)
+
+	w := serveGzip(r, "GET", "/long", "deflate;q=0.5, gzip", nil)
+	h := w.Header()
+	if h.Get("Content-Encoding") != "gzip" || h.Get("Vary") != "Accept-Encoding" ||
+		h.Get("Content-Type") != "text/plain; charset=utf-8" || w.Body.Len() >= len(long) {
+		t.Fatalf("long body answered %v, %d bytes", h, w.Body.Len())
+	}
+	if body := gunzip(t, w.Body.Bytes()); body != long {
+		t.Fatalf("decompressed %d bytes", len(body))
+	}
+
+	w = serveGzip(r, "GET", "/long", "gzip;q=0, deflate", nil)
+	if w.Header().Get("Content-Encoding") != "deflate" {
+		t.Fatalf("deflate not negotiated: %v", w.Header())
+	}
+	body, _ := ioutil.ReadAll(flate.NewReader(w.Body))
+	if string(body) != long {
+		t.Fatalf("inflated %d bytes", len(body))
+	}
+
+	tests := []struct {
+		path, accept string
+	}{
+		{"/long", ""},
+		{"/long", "br"},
+		{"/raw/long", "gzip"},
+		{"/short", "gzip"},
+		{"/png", "gzip"},
+		{"/empty", "gzip"},
+	}
+	for _, tt := range tests {
+		w := serveGzip(r, "GET", tt.path, tt.accept, nil)
+		if w.Header().Get("Content-Encoding") != "" {
+			t.Fatalf("%s with %q was compressed", tt.path, tt.accept)
+		}
+	}
+	if w := serveGzip(r, "GET", "/short", "gzip", nil); w.Body.String() != `{"a":1}`+"\n" {
+		t.Fatalf("short body answered %q", w.Body.String())
+	}
+	if w := serveGzip(r, "GET", "/empty", "gzip", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("empty body answered %d", w.Code)
+	}
+}
+
+func TestGzipWeakensETag(t *testing.T) {
+	r := New()
+	r.Use(Gzip(gzip.DefaultCompression, GzipOptions{MinLength: 10}))
+	r.StaticEmbed("/", newStaticFS(), "public")
+
+	w := serveGzip(r, "GET", "/docs/intro.txt", "", nil)
+	strong := w.Header().Get("ETag")
+	w = serveGzip(r, "GET", "/docs/intro.txt", "gzip", nil)
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("ETag") != "W/"+strong {
+		t.Fatalf("compressed file answered %v, identity ETag %s", w.Header(), strong)
+	}
+	if body := gunzip(t, w.Body.Bytes()); body != "0123456789" {
+		t.Fatalf("decompressed to %q", body)
+	}
+
+	req := httptest.NewRequest("GET", "/docs/intro.txt", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("If-None-Match", "W/"+strong)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("weak ETag revalidation answered %d", w.Code)
+	}
+}
+
+func TestGzipFlush(t *testing.T) {
+	r := newGzipEngine()
+	w := serveGzip(r, "GET", "/stream", "gzip", nil)
+	if w.Header().Get("Content-Encoding") != "gzip" || !w.Flushed {
+		t.Fatalf("stream answered %v", w.Header())
+	}
+	if body := gunzip(t, w.Body.Bytes()); body != "event: tick\ndata: 1\n\nevent: tick\ndata: 2\n\n" {
+		t.Fatalf("stream decompressed to %q", body)
+	}
+}
+
+func TestGzipRequestBody(t *testing.T) {
+	r := newGzipEngine()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte("hello gee"))
+	zw.Close()
+	compressed := buf.Bytes()
+
+	req := httptest.NewRequest("POST", "/echo", bytes.NewReader(compressed))
+	req.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "hello gee" {
+		t.Fatalf("gzip body answered %d %q", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("POST", "/echo", strings.NewReader("not gzip"))
+	req.Header.Set("Content-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid gzip body answered %d", w.Code)
+	}
+
+	// the limit applies to the decompressed size
+	buf.Reset()
+	zw.Reset(&buf)
+	zw.Write(bytes.Repeat([]byte("a"), 1000))
+	zw.Close()
+	r.MaxRequestBodySize = 100
+	req = httptest.NewRequest("POST", "/echo", bytes.NewReader(buf.Bytes()))
+	req.Header.Set("Content-Encoding", "gzip")
+	req.ContentLength = 0
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("large gzip body answered %d", w.Code)
+	}
+}
+
+func TestGzipBomb(t *testing.T) {
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	zw.Write(make([]byte, defaultGzipMaxDecompressedSize+1))
+	zw.Close()
+	if buf.Len() > 64<<10 {
+		t.Fatalf("the bomb is %d bytes", buf.Len())
+	}
+
+	// no MaxRequestBodySize, the default cap of Gzip applies
+	r := newGzipEngine()
+	req := httptest.NewRequest("POST", "/echo", bytes.NewReader(buf.Bytes()))
+	req.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("gzip bomb answered %d", w.Code)
+	}
+}
+
+func BenchmarkGzip(b *testing.B) {
+	r := newGzipEngine()
+	req := httptest.NewRequest("GET", "/long", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
@@ -0,0 +1,161 @@
+package gee
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// htmlRender holds the html templates of the engine. They are parsed when
+// they are loaded, and again on every render in DebugMode to pick up edits.
+type htmlRender struct {
+	mu      sync.RWMutex
+	funcMap template.FuncMap
+	// load parses the template sets by page name,
+	// a single set shared by all names is stored under ""
+	load func(funcMap template.FuncMap) (map[string]*template.Template, error)
+	sets map[string]*template.Template
+}
+
+// lookup returns the template set to execute the template name with
+func (r *htmlRender) lookup(name string) (*template.Template, error) {
+	r.mu.RLock()
+	load, funcMap, sets := r.load, r.funcMap, r.sets
+	r.mu.RUnlock()
+	if load == nil {
+		return nil, fmt.Errorf("gee: no html templates loaded")
+	}
+	if Mode() == DebugMode {
+		var err error
+		if sets, err = load(funcMap); err != nil {
+			return nil, err
+		}
+	}
+
+	if t, ok := sets[name]; ok {
+		return t, nil
+	}
+	if t, ok := sets[""]; ok {
+		return t, nil
+	}
+	return nil, fmt.Errorf("gee: html template %q not found", name)
+}
+
+// setLoader parses the templates of load, it panics on errors
+// so that broken templates are found when the engine is set up
+func (r *htmlRender) setLoader(load func(template.FuncMap) (map[string]*template.Template, error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sets, err := load(r.funcMap)
+	if err != nil {
+		panic(err)
+	}
+	r.load, r.sets = load, sets
+}
+
+// for custom render function. The functions templates use must be set
+// before they are loaded, the loaded templates are parsed again with it.
+func (engine *Engine) SetFuncMap(funcMap template.FuncMap) {
+	r := &engine.html
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.load != nil {
+		sets, err := r.load(funcMap)
+		if err != nil {
+			panic(err)
+		}
+		r.sets = sets
+	}
+	r.funcMap = funcMap
+}
+
+// LoadHTMLGlob parses the files matching pattern into a single set,
+// every template is executed by the name of its file or define
+func (engine *Engine) LoadHTMLGlob(pattern string) {
+	engine.html.setLoader(func(funcMap template.FuncMap) (map[string]*template.Template, error) {
+		t, err := template.New("").Funcs(funcMap).ParseGlob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]*template.Template{"": t}, nil
+	})
+}
+
+// LoadHTMLPages parses every file matching pagesGlob into its own set
+// together with the files matching sharedGlobs, e.g. layouts and partials,
+// so that pages may define the same blocks. A page is rendered by its path
+// below the directory of pagesGlob, e.g. "users/index.tmpl" for
+// "pages/*/*.tmpl", and usually starts with {{template "layout" .}}.
+func (engine *Engine) LoadHTMLPages(pagesGlob string, sharedGlobs ...string) {
+	engine.html.setLoader(func(funcMap template.FuncMap) (map[string]*template.Template, error) {
+		return parsePages(funcMap, filepath.Glob, ioutil.ReadFile, pagesGlob, sharedGlobs)
+	})
+}
+
+// LoadHTMLFS is LoadHTMLPages for the files of fsys, e.g. an embed.FS
+func (engine *Engine) LoadHTMLFS(fsys fs.FS, pagesGlob string, sharedGlobs ...string) {
+	engine.html.setLoader(func(funcMap template.FuncMap) (map[string]*template.Template, error) {
+		glob := func(pattern string) ([]string, error) {
+			return fs.Glob(fsys, pattern)
+		}
+		readFile := func(name string) ([]byte, error) {
+			return fs.ReadFile(fsys, name)
+		}
+		return parsePages(funcMap, glob, readFile, pagesGlob, sharedGlobs)
+	})
+}
+
+func parsePages(funcMap template.FuncMap, glob func(string) ([]string, error),
+	readFile func(string) ([]byte, error), pagesGlob string, sharedGlobs []string) (map[string]*template.Template, error) {
+	pages, err := glob(pagesGlob)
+	if err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("gee: pattern %q matches no files", pagesGlob)
+	}
+	var shared []string
+	for _, pattern := range sharedGlobs {
+		files, err := glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		shared = append(shared, files...)
+	}
+
+	dir := globDir(filepath.ToSlash(pagesGlob))
+	sets := make(map[string]*template.Template, len(pages))
+	for _, page := range pages {
+		name := strings.TrimPrefix(filepath.ToSlash(page), dir)
+		t := template.New(name).Funcs(funcMap)
+		// the page is parsed last, so its blocks replace the shared ones
+		for _, file := range append(shared[:len(shared):len(shared)], page) {
+			b, err := readFile(file)
+			if err != nil {
+				return nil, err
+			}
+			tmpl := t
+			if file != page {
+				tmpl = t.New(filepath.Base(file))
+			}
+			if _, err := tmpl.Parse(string(b)); err != nil {
+				return nil, err
+			}
+		}
+		sets[name] = t
+	}
+	return sets, nil
+}
+
+// globDir returns the directory of the slash separated pattern
+// up to its first meta character, with a trailing slash
+func globDir(pattern string) string {
+	if i := strings.IndexAny(pattern, `*?[\`); i >= 0 {
+		pattern = pattern[:i]
+	}
+	return pattern[:strings.LastIndexByte(pattern, '/')+1]
+}
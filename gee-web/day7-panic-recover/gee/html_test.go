@@ -0,0 +1,148 @@
+package gee
+
+import (
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func renderHTML(t *testing.T, r *Engine, name string) (int, string) {
+	t.Helper()
+	r.GET("/"+name, func(c *Context) {
+		c.HTML(http.StatusOK, name, "gee")
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/"+name, nil))
+	return w.Code, w.Body.String()
+}
+
+func writeFile(t *testing.T, name, content string) {
+	t.Helper()
+	if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+var layoutFS = fstest.MapFS{
+	"layouts/base.tmpl":      {Data: []byte(`{{define "base"}}<main>{{block "content" .}}{{end}}</main>{{end}}`)},
+	"pages/home.tmpl":        {Data: []byte(`{{template "base" .}}{{define "content"}}home {{upper .}}{{end}}`)},
+	"pages/about.tmpl":       {Data: []byte(`{{template "base" .}}{{define "content"}}about{{end}}`)},
+	"pages/users/index.tmpl": {Data: []byte(`users`)},
+	"pages/posts/index.tmpl": {Data: []byte(`posts`)},
+}
+
+func TestLoadHTMLFSPerPage(t *testing.T) {
+	r := New()
+	r.SetFuncMap(template.FuncMap{"upper": strings.ToUpper})
+	r.LoadHTMLFS(layoutFS, "pages/*.tmpl", "layouts/*.tmpl")
+
+	if code, body := renderHTML(t, r, "home.tmpl"); code != 200 || body != "<main>home GEE</main>" {
+		t.Fatalf("home rendered %d %q", code, body)
+	}
+	// the loaded templates are parsed again with a new func map
+	r.SetFuncMap(template.FuncMap{"upper": strings.Title})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/home.tmpl", nil))
+	if w.Body.String() != "<main>home Gee</main>" {
+		t.Fatalf("home rendered %q after SetFuncMap", w.Body.String())
+	}
+	if _, body := renderHTML(t, r, "about.tmpl"); body != "<main>about</main>" {
+		t.Fatalf("pages defining the same block collide: %q", body)
+	}
+	if code, _ := renderHTML(t, r, "missing.tmpl"); code != 500 {
+		t.Fatalf("missing page rendered %d", code)
+	}
+}
+
+func TestLoadHTMLFSNested(t *testing.T) {
+	r := New()
+	r.LoadHTMLFS(layoutFS, "pages/*/*.tmpl")
+	// pages are named by their path, not by their file name
+	if _, body := renderHTML(t, r, "users/index.tmpl"); body != "users" {
+		t.Fatalf("users/index.tmpl rendered %q", body)
+	}
+	if _, body := renderHTML(t, r, "posts/index.tmpl"); body != "posts" {
+		t.Fatalf("posts/index.tmpl rendered %q", body)
+	}
+}
+
+func TestLoadHTMLPanics(t *testing.T) {
+	tests := []struct {
+		name string
+		load func(r *Engine)
+	}{
+		{"no files", func(r *Engine) { r.LoadHTMLFS(layoutFS, "missing/*.tmpl") }},
+		{"bad pattern", func(r *Engine) { r.LoadHTMLGlob("[") }},
+		{"undefined function", func(r *Engine) { r.LoadHTMLFS(layoutFS, "pages/home.tmpl", "layouts/*.tmpl") }},
+		{"parse error", func(r *Engine) {
+			r.LoadHTMLFS(fstest.MapFS{"bad.tmpl": {Data: []byte(`{{if}}`)}}, "*.tmpl")
+		}},
+	}
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("%s: loading the templates should panic", tt.name)
+				}
+			}()
+			tt.load(New())
+		}()
+	}
+}
+
+func TestLoadHTMLPagesReload(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "pages"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	page := filepath.Join(dir, "pages", "index.tmpl")
+	writeFile(t, filepath.Join(dir, "base.tmpl"), `{{define "base"}}[{{template "content" .}}]{{end}}`)
+	writeFile(t, page, `{{template "base" .}}{{define "content"}}v1{{end}}`)
+
+	r := New()
+	r.LoadHTMLPages(filepath.Join(dir, "pages", "*.tmpl"), filepath.Join(dir, "*.tmpl"))
+	r.GET("/", func(c *Context) {
+		c.HTML(http.StatusOK, "index.tmpl", nil)
+	})
+	render := func() string {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		return w.Body.String()
+	}
+
+	if body := render(); body != "[v1]" {
+		t.Fatalf("rendered %q", body)
+	}
+	writeFile(t, page, `{{template "base" .}}{{define "content"}}v2{{end}}`)
+	if body := render(); body != "[v1]" {
+		t.Fatalf("release mode reloaded, rendered %q", body)
+	}
+
+	SetMode(DebugMode)
+	defer SetMode(ReleaseMode)
+	if body := render(); body != "[v2]" {
+		t.Fatalf("debug mode did not reload, rendered %q", body)
+	}
+	writeFile(t, page, `{{template "base" .}}{{define "content"}}v3{{end}}`)
+	if body := render(); body != "[v3]" {
+		t.Fatalf("debug mode did not reload, rendered %q", body)
+	}
+}
+
+func TestLoadHTMLGlob(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "hello.tmpl"), `hello {{.}}`)
+
+	r := New()
+	r.LoadHTMLGlob(filepath.Join(dir, "*.tmpl"))
+	code, body := renderHTML(t, r, "hello.tmpl")
+	if code != 200 || body != "hello gee" {
+		t.Fatalf("rendered %d %q", code, body)
+	}
+}
@@ -0,0 +1,198 @@
+package gee
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"time"
+)
+
+// JWTClaimsKey is the context key JWT stores the claims with by default
+const JWTClaimsKey = "jwt_claims"
+
+// Errors of ParseJWT, JWT passes them to Context.Error
+var (
+	ErrTokenMalformed   = errors.New("token is malformed")
+	ErrTokenAlgorithm   = errors.New("token algorithm is not accepted")
+	ErrTokenSignature   = errors.New("token signature is invalid")
+	ErrTokenExpired     = errors.New("token is expired")
+	ErrTokenNotValidYet = errors.New("token is not valid yet")
+	ErrTokenAudience    = errors.New("token audience is invalid")
+	ErrTokenIssuer      = errors.New("token issuer is invalid")
+)
+
+// JWTConfig configures the JWT middleware. HS256 tokens are accepted if
+// Key is set and not empty, RS256 tokens if PublicKey is set.
+type JWTConfig struct {
+	Key       []byte         // HS256 secret
+	PublicKey *rsa.PublicKey // RS256 public key
+	// Audience and Issuer, if set, must match the aud and iss claims
+	Audience string
+	Issuer   string
+	// Leeway is the clock skew allowed when checking exp and nbf
+	Leeway time.Duration
+	// Realm is sent in the WWW-Authenticate challenge
+	Realm string
+	// ContextKey is the key the claims are stored with, JWTClaimsKey
+	// if it is empty
+	ContextKey string
+	// Now returns the current time, it defaults to time.Now
+	Now func() time.Time
+}
+
+// JWTClaims are the claims of a JSON Web Token, numbers are float64
+type JWTClaims map[string]interface{}
+
+// Subject returns the sub claim
+func (claims JWTClaims) Subject() string {
+	s, _ := claims["sub"].(string)
+	return s
+}
+
+// JWT verifies the JSON Web Token of the Authorization: Bearer header
+// and stores its claims with the ContextKey of config. Invalid tokens
+// are answered with 401.
+func JWT(config JWTConfig) HandlerFunc {
+	if config.Key != nil && len(config.Key) == 0 {
+		// e.g. read from an unset environment variable,
+		// anyone could sign tokens with it
+		panic("gee: JWT key is empty")
+	}
+	if config.Key == nil && config.PublicKey == nil {
+		panic("gee: JWT needs a Key or a PublicKey")
+	}
+	if config.ContextKey == "" {
+		config.ContextKey = JWTClaimsKey
+	}
+	return bearerAuth(config.Realm, func(c *Context, token string) error {
+		claims, err := ParseJWT(token, config)
+		if err != nil {
+			return err
+		}
+		c.Set(config.ContextKey, claims)
+		return nil
+	})
+}
+
+// ParseJWT verifies the signature of token and its exp, nbf, aud and iss
+// claims, and returns its claims
+func ParseJWT(token string, config JWTConfig) (JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenMalformed
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+
+	signed := []byte(token[:len(parts[0])+1+len(parts[1])])
+	switch {
+	case header.Alg == "HS256" && len(config.Key) > 0:
+		mac := hmac.New(sha256.New, config.Key)
+		mac.Write(signed)
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return nil, ErrTokenSignature
+		}
+	case header.Alg == "RS256" && config.PublicKey != nil:
+		hash := sha256.Sum256(signed)
+		if rsa.VerifyPKCS1v15(config.PublicKey, crypto.SHA256, hash[:], sig) != nil {
+			return nil, ErrTokenSignature
+		}
+	default:
+		return nil, ErrTokenAlgorithm
+	}
+
+	var claims JWTClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if err := claims.validate(config); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return ErrTokenMalformed
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return ErrTokenMalformed
+	}
+	return nil
+}
+
+func (claims JWTClaims) validate(config JWTConfig) error {
+	now := time.Now()
+	if config.Now != nil {
+		now = config.Now()
+	}
+	exp, hasExp, err := claims.time("exp")
+	if err != nil {
+		return err
+	}
+	if hasExp && !now.Before(exp.Add(config.Leeway)) {
+		return ErrTokenExpired
+	}
+	nbf, hasNbf, err := claims.time("nbf")
+	if err != nil {
+		return err
+	}
+	if hasNbf && now.Add(config.Leeway).Before(nbf) {
+		return ErrTokenNotValidYet
+	}
+	if config.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != config.Issuer {
+			return ErrTokenIssuer
+		}
+	}
+	if config.Audience != "" && !claims.hasAudience(config.Audience) {
+		return ErrTokenAudience
+	}
+	return nil
+}
+
+// time returns a NumericDate claim and whether it is present
+func (claims JWTClaims) time(name string) (time.Time, bool, error) {
+	v, ok := claims[name]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	seconds, ok := v.(float64)
+	// far beyond any meaningful date the time would overflow
+	if !ok || math.IsNaN(seconds) || math.Abs(seconds) > 1<<62 {
+		return time.Time{}, false, ErrTokenMalformed
+	}
+	sec, frac := math.Modf(seconds)
+	return time.Unix(int64(sec), int64(frac*1e9)), true, nil
+}
+
+// hasAudience reports whether the aud claim, a string or
+// an array of strings, contains audience
+func (claims JWTClaims) hasAudience(audience string) bool {
+	switch aud := claims["aud"].(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package gee
+
+import "os"
+
+// Modes of gee, set by SetMode or the GEE_MODE environment variable
+const (
+	// DebugMode reloads html templates on every render
+	DebugMode = "debug"
+	// ReleaseMode parses html templates once
+	ReleaseMode = "release"
+)
+
+var geeMode = ReleaseMode
+
+func init() {
+	if mode := os.Getenv("GEE_MODE"); mode != "" {
+		SetMode(mode)
+	}
+}
+
+// SetMode sets the mode of gee, DebugMode or ReleaseMode.
+// ReleaseMode is the default.
+func SetMode(mode string) {
+	switch mode {
+	case DebugMode, ReleaseMode:
+		geeMode = mode
+	default:
+		panic("gee: unknown mode " + mode)
+	}
+}
+
+// Mode returns the current mode of gee
+func Mode() string {
+	return geeMode
+}
@@ -0,0 +1,154 @@
+package gee
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// MIME types offered by Context.Negotiate
+const (
+	MIMEJSON  = "application/json"
+	MIMEHTML  = "text/html"
+	MIMEXML   = "application/xml"
+	MIMEXML2  = "text/xml"
+	MIMEPlain = "text/plain"
+)
+
+// ErrNotAcceptable is collected when no offered format is accepted
+var ErrNotAcceptable = errors.New("gee: the accepted formats are not offered")
+
+// Negotiate describes the formats Context.Negotiate may answer with.
+// The data of a format falls back to Data when it is nil.
+type Negotiate struct {
+	Offered  []string
+	HTMLName string
+	HTMLData interface{}
+	JSONData interface{}
+	XMLData  interface{}
+	Data     interface{}
+}
+
+func (n Negotiate) data(data interface{}) interface{} {
+	if data != nil {
+		return data
+	}
+	return n.Data
+}
+
+// Negotiate renders the data in the offered format the client prefers,
+// it aborts with 406 if none of them is accepted. Offers may have
+// parameters, e.g. "application/json; charset=utf-8".
+func (c *Context) Negotiate(code int, config Negotiate) {
+	// shared caches must not answer other clients with this format
+	addVary(c.Writer.Header(), "Accept")
+	format, _, _ := mime.ParseMediaType(c.NegotiateFormat(config.Offered...))
+	switch format {
+	case MIMEJSON:
+		c.JSON(code, config.data(config.JSONData))
+	case MIMEHTML:
+		c.HTML(code, config.HTMLName, config.data(config.HTMLData))
+	case MIMEXML, MIMEXML2:
+		c.XML(code, config.data(config.XMLData))
+	case MIMEPlain:
+		c.String(code, "%v", config.Data)
+	default:
+		c.AbortWithError(http.StatusNotAcceptable, ErrNotAcceptable)
+	}
+}
+
+// NegotiateFormat returns the offered format the Accept header of the
+// request prefers, or "" if none is acceptable. The first one wins when
+// there is no Accept header or several are preferred equally.
+func (c *Context) NegotiateFormat(offered ...string) string {
+	accept := c.Req.Header.Get("Accept")
+	if accept == "" {
+		if len(offered) == 0 {
+			return ""
+		}
+		return offered[0]
+	}
+
+	ranges := parseAccept(accept)
+	best, bestQ := "", 0.0
+	for _, offer := range offered {
+		if q := acceptQuality(ranges, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// mediaRange is one entry of an Accept header, e.g. text/*;q=0.5
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		typ, subtype := splitMIME(params[0])
+		if typ == "" {
+			continue
+		}
+		r := mediaRange{typ: typ, subtype: subtype, q: 1}
+		for _, p := range params[1:] {
+			p = strings.TrimSpace(p)
+			if !strings.HasPrefix(p, "q=") {
+				continue
+			}
+			q, err := strconv.ParseFloat(p[2:], 64)
+			if err != nil || q < 0 || q > 1 {
+				q = 0
+			}
+			r.q = q
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+// acceptQuality returns the q-value of the most specific range
+// matching the offered type, 0 if there is none
+func acceptQuality(ranges []mediaRange, offer string) float64 {
+	typ, subtype := splitMIME(offer)
+	q, specificity := 0.0, 0
+	for _, r := range ranges {
+		s := 0
+		switch {
+		case r.typ == typ && r.subtype == subtype:
+			s = 3
+		case r.typ == typ && r.subtype == "*":
+			s = 2
+		case r.typ == "*" && r.subtype == "*":
+			s = 1
+		default:
+			continue
+		}
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+	return q
+}
+
+// splitMIME splits a media type without parameters into its lower
+// case type and subtype, a bare "*" is read as */*
+func splitMIME(mime string) (typ, subtype string) {
+	if i := strings.IndexByte(mime, ';'); i >= 0 {
+		mime = mime[:i]
+	}
+	mime = strings.ToLower(strings.TrimSpace(mime))
+	if mime == "*" {
+		return "*", "*"
+	}
+	i := strings.IndexByte(mime, '/')
+	if i <= 0 || i == len(mime)-1 {
+		return "", ""
+	}
+	return mime[:i], mime[i+1:]
+}
@@ -0,0 +1,72 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	offered := []string{MIMEJSON, MIMEHTML, MIMEXML}
+	tests := []struct {
+		accept, want string
+	}{
+		{"", MIMEJSON},
+		{"*/*", MIMEJSON},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", MIMEHTML},
+		{"application/xml", MIMEXML},
+		{"application/*;q=0.5, text/html;q=0.4", MIMEJSON},
+		{"application/json;q=0.2, application/xml;q=0.3", MIMEXML},
+		{"Application/XML; charset=utf-8", MIMEXML},
+		{"*/*;q=0.1, application/json;q=0", MIMEHTML},
+		{"image/png", ""},
+		{"application/json;q=0", ""},
+	}
+	for _, tt := range tests {
+		c := &Context{Req: httptest.NewRequest("GET", "/", nil)}
+		if tt.accept != "" {
+			c.Req.Header.Set("Accept", tt.accept)
+		}
+		if got := c.NegotiateFormat(offered...); got != tt.want {
+			t.Fatalf("Accept %q negotiated %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	type user struct {
+		Name string `xml:"name"`
+	}
+	r := New()
+	r.LoadHTMLFS(fstest.MapFS{
+		"user.tmpl": {Data: []byte(`<p>{{.name}}</p>`)},
+	}, "*.tmpl")
+	r.GET("/user", func(c *Context) {
+		c.Negotiate(http.StatusOK, Negotiate{
+			Offered:  []string{MIMEJSON + "; charset=utf-8", MIMEHTML, MIMEXML},
+			HTMLName: "user.tmpl",
+			Data:     H{"name": "gee"},
+			XMLData:  user{"gee"},
+		})
+	})
+	tests := []struct {
+		accept string
+		code   int
+		body   string
+	}{
+		{"application/json", 200, `{"name":"gee"}` + "\n"},
+		{"text/html", 200, "<p>gee</p>"},
+		{"text/xml;q=0.1, application/xml", 200, "<user><name>gee</name></user>"},
+		{"image/*", http.StatusNotAcceptable, `{"message":"Not Acceptable"}` + "\n"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/user", nil)
+		req.Header.Set("Accept", tt.accept)
+		r.ServeHTTP(w, req)
+		if w.Code != tt.code || w.Body.String() != tt.body || w.Header().Get("Vary") != "Accept" {
+			t.Fatalf("Accept %q answered %d %v %q", tt.accept, w.Code, w.Header(), w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package gee
+
+import (
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitConfig configures the RateLimit middleware
+type RateLimitConfig struct {
+	// Rate is the number of requests per second a key may make
+	Rate float64
+	// Burst is the number of requests a key may make at once,
+	// it defaults to 1
+	Burst int
+	// KeyFunc returns the key requests are limited by, requests with
+	// an empty key are not limited. It defaults to RateLimitByIP.
+	KeyFunc func(*Context) string
+	// Store keeps the token buckets, it defaults to a MemoryRateLimitStore
+	Store RateLimitStore
+	// Handler answers the limited requests, after the headers are set.
+	// It defaults to a plain text 429.
+	Handler HandlerFunc
+	// Now returns the current time, it defaults to time.Now
+	Now func() time.Time
+}
+
+// RateLimitResult is the state of a token bucket after taking a token
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int           // tokens left in the bucket
+	RetryAfter time.Duration // until the next token, if none was left
+	Reset      time.Duration // until the bucket is full again
+}
+
+// RateLimitStore keeps a token bucket per key, e.g. in memory or
+// in a database shared by several servers
+type RateLimitStore interface {
+	// Take takes a token from the bucket of key, which gains rate
+	// tokens per second and holds at most burst tokens
+	Take(key string, rate float64, burst int, now time.Time) RateLimitResult
+}
+
+// RateLimitByIP limits requests by Context.ClientIP
+func RateLimitByIP(c *Context) string {
+	return c.ClientIP()
+}
+
+// RateLimitByHeader limits requests by the value of a header, e.g. an API key
+func RateLimitByHeader(name string) func(*Context) string {
+	return func(c *Context) string {
+		return c.Req.Header.Get(name)
+	}
+}
+
+// RateLimitByParam limits requests by a route parameter
+func RateLimitByParam(name string) func(*Context) string {
+	return func(c *Context) string {
+		return c.Param(name)
+	}
+}
+
+// RateLimit limits the requests of every key with a token bucket.
+// The X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset
+// headers are set on every response, limited requests are answered
+// with 429 and Retry-After.
+func RateLimit(config RateLimitConfig) HandlerFunc {
+	if config.Rate <= 0 {
+		panic("gee: rate limit must be positive")
+	}
+	if config.Burst < 1 {
+		config.Burst = 1
+	}
+	if config.KeyFunc == nil {
+		config.KeyFunc = RateLimitByIP
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryRateLimitStore()
+	}
+	if config.Handler == nil {
+		config.Handler = func(c *Context) {
+			c.String(http.StatusTooManyRequests, "429 TOO MANY REQUESTS\n")
+		}
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	limit := strconv.Itoa(config.Burst)
+
+	return func(c *Context) {
+		key := config.KeyFunc(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+		res := config.Store.Take(key, config.Rate, config.Burst, config.Now())
+		header := c.Writer.Header()
+		header.Set("X-RateLimit-Limit", limit)
+		header.Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		header.Set("X-RateLimit-Reset", ceilSeconds(res.Reset))
+		if !res.Allowed {
+			header.Set("Retry-After", ceilSeconds(res.RetryAfter))
+			c.Abort()
+			config.Handler(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// ceilSeconds formats d as whole seconds, rounded up
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+// rateLimitShards is the number of independently locked parts of the
+// memory store, so that requests of different keys rarely contend
+const rateLimitShards = 32
+
+// MemoryRateLimitStore keeps the token buckets in memory. Buckets that
+// have refilled are evicted, they are the same as a new bucket.
+type MemoryRateLimitStore struct {
+	shards [rateLimitShards]rateLimitShard
+}
+
+type rateLimitShard struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	nextSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	rate   float64
+	burst  int
+	last   time.Time
+}
+
+// rateLimitSweepInterval is how often a shard evicts its idle buckets
+const rateLimitSweepInterval = time.Minute
+
+// NewMemoryRateLimitStore returns an empty MemoryRateLimitStore
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	s := &MemoryRateLimitStore{}
+	for i := range s.shards {
+		s.shards[i].buckets = make(map[string]*tokenBucket)
+	}
+	return s
+}
+
+// refill adds the tokens gained since the last update
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(b.burst), b.tokens+elapsed*b.rate)
+		b.last = now
+	}
+}
+
+// Take implements RateLimitStore
+func (s *MemoryRateLimitStore) Take(key string, rate float64, burst int, now time.Time) RateLimitResult {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	shard := &s.shards[h.Sum32()%rateLimitShards]
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	shard.sweep(now)
+	b, ok := shard.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(burst), last: now}
+		shard.buckets[key] = b
+	}
+	b.rate, b.burst = rate, burst
+	b.refill(now)
+
+	var res RateLimitResult
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = seconds((float64(burst) - b.tokens) / rate)
+	return res
+}
+
+// Len returns the number of buckets kept
+func (s *MemoryRateLimitStore) Len() int {
+	n := 0
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.Lock()
+		n += len(shard.buckets)
+		shard.mu.Unlock()
+	}
+	return n
+}
+
+// sweep evicts the full buckets, at most once per rateLimitSweepInterval
+func (shard *rateLimitShard) sweep(now time.Time) {
+	if now.Before(shard.nextSweep) {
+		return
+	}
+	shard.nextSweep = now.Add(rateLimitSweepInterval)
+	for key, b := range shard.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.burst) {
+			delete(shard.buckets, key)
+		}
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
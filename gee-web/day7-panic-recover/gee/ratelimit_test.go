@@ -0,0 +1,156 @@
+package gee
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a time source the tests move forward by hand
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func TestRateLimit(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	r := New()
+	r.Use(RateLimit(RateLimitConfig{Rate: 0.5, Burst: 2, Now: clock.Now}))
+	r.GET("/", func(c *Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	serve := func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for i, remaining := range []string{"1", "0"} {
+		w := serve("10.0.0.1")
+		if w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Remaining") != remaining ||
+			w.Header().Get("X-RateLimit-Limit") != "2" {
+			t.Fatalf("request %d answered %d %v", i, w.Code, w.Header())
+		}
+	}
+	w := serve("10.0.0.1")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "2" ||
+		w.Header().Get("X-RateLimit-Reset") != "4" {
+		t.Fatalf("limited request answered %d %v", w.Code, w.Header())
+	}
+	if w := serve("10.0.0.2"); w.Code != http.StatusOK {
+		t.Fatalf("another client was limited: %d", w.Code)
+	}
+
+	clock.Advance(time.Second)
+	if w := serve("10.0.0.1"); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Fatalf("request before the refill answered %d %v", w.Code, w.Header())
+	}
+	clock.Advance(time.Second)
+	if w := serve("10.0.0.1"); w.Code != http.StatusOK {
+		t.Fatalf("request after the refill answered %d", w.Code)
+	}
+}
+
+func TestRateLimitKeyFunc(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	r := New()
+	limited := r.Group("/users")
+	limited.Use(RateLimit(RateLimitConfig{
+		Rate:    1,
+		KeyFunc: RateLimitByParam("id"),
+		Now:     clock.Now,
+		Handler: func(c *Context) {
+			c.JSON(http.StatusTooManyRequests, H{"message": "slow down"})
+		},
+	}))
+	limited.GET("/:id", func(c *Context) {})
+
+	for i, tt := range []struct {
+		path string
+		code int
+	}{
+		{"/users/1", 200}, {"/users/2", 200}, {"/users/1", 429},
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+		if w.Code != tt.code {
+			t.Fatalf("request %d to %s answered %d", i, tt.path, w.Code)
+		}
+		if tt.code == 429 && w.Body.String() != `{"message":"slow down"}`+"\n" {
+			t.Fatalf("limited request answered %q", w.Body.String())
+		}
+	}
+}
+
+func TestMemoryRateLimitStoreEviction(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	store := NewMemoryRateLimitStore()
+	for i := 0; i < 100; i++ {
+		store.Take(fmt.Sprint("key", i), 1, 5, clock.Now())
+	}
+	if store.Len() != 100 {
+		t.Fatalf("%d buckets kept", store.Len())
+	}
+	clock.Advance(2 * rateLimitSweepInterval)
+	for i := 0; i < 100; i++ {
+		store.Take(fmt.Sprint("fresh", i), 1, 5, clock.Now())
+	}
+	if store.Len() != 100 {
+		t.Fatalf("idle buckets were not evicted, %d kept", store.Len())
+	}
+}
+
+func TestMemoryRateLimitStoreConcurrent(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	now := time.Unix(1000, 0)
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		allowed int
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if store.Take("shared", 1, 10, now).Allowed {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != 10 {
+		t.Fatalf("%d requests allowed, want the burst of 10", allowed)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	c := &Context{engine: New(), Req: httptest.NewRequest("GET", "/", nil)}
+	c.Req.RemoteAddr = "10.0.0.1:1234"
+	c.Req.Header.Set("X-Forwarded-For", "1.2.3.4, 10.0.0.1")
+	if ip := c.ClientIP(); ip != "10.0.0.1" {
+		t.Fatalf("ClientIP %q without a trusted proxy", ip)
+	}
+	c.engine.ForwardedByClientIP = true
+	if ip := c.ClientIP(); ip != "1.2.3.4" {
+		t.Fatalf("ClientIP %q behind a proxy", ip)
+	}
+}
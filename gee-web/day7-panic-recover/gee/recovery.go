@@ -29,6 +29,11 @@ func Recovery() HandlerFunc {
 			if err := recover(); err != nil {
 				message := fmt.Sprintf("%s", err)
 				log.Printf("%s\n\n", trace(message))
+				if c.Writer.Written() {
+					// too late to change the response, just stop the chain
+					c.Abort()
+					return
+				}
 				c.Fail(http.StatusInternalServerError, "Internal Server Error")
 			}
 		}()
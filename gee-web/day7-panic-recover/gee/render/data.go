@@ -0,0 +1,56 @@
+package render
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// Data writes raw bytes, the Content-Type is sniffed
+// by net/http if ContentType is empty
+type Data struct {
+	ContentType string
+	Data        []byte
+}
+
+// Reader copies Reader to the response, Content-Length is set
+// unless ContentLength is negative. Headers are added as well.
+type Reader struct {
+	ContentType   string
+	ContentLength int64
+	Reader        io.Reader
+	Headers       map[string]string
+}
+
+func (r Data) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	_, err := w.Write(r.Data)
+	return err
+}
+
+func (r Data) WriteContentType(w http.ResponseWriter) {
+	if r.ContentType != "" {
+		writeContentType(w, []string{r.ContentType})
+	}
+}
+
+func (r Reader) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	header := w.Header()
+	if r.ContentLength >= 0 {
+		header.Set("Content-Length", strconv.FormatInt(r.ContentLength, 10))
+	}
+	for k, v := range r.Headers {
+		if header.Get(k) == "" {
+			header.Set(k, v)
+		}
+	}
+	_, err := io.Copy(w, r.Reader)
+	return err
+}
+
+func (r Reader) WriteContentType(w http.ResponseWriter) {
+	if r.ContentType != "" {
+		writeContentType(w, []string{r.ContentType})
+	}
+}
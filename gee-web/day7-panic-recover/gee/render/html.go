@@ -0,0 +1,32 @@
+package render
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+)
+
+// HTML executes the template Name of Template with Data
+type HTML struct {
+	Template *template.Template
+	Name     string
+	Data     interface{}
+}
+
+var htmlContentType = []string{"text/html; charset=utf-8"}
+
+// Render executes the template into a buffer first,
+// so that a failing template doesn't leave a partial page
+func (r HTML) Render(w http.ResponseWriter) error {
+	var buf bytes.Buffer
+	if err := r.Template.ExecuteTemplate(&buf, r.Name, r.Data); err != nil {
+		return err
+	}
+	writeContentType(w, htmlContentType)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func (r HTML) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, htmlContentType)
+}
@@ -0,0 +1,143 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"regexp"
+)
+
+// JSON writes Data as JSON, with <, > and & escaped
+type JSON struct {
+	Data interface{}
+}
+
+// IndentedJSON writes Data as pretty printed JSON
+type IndentedJSON struct {
+	Data interface{}
+}
+
+// SecureJSON writes Data as JSON, prefixed with Prefix if it is an array
+// to prevent JSON hijacking, e.g. with "while(1);"
+type SecureJSON struct {
+	Prefix string
+	Data   interface{}
+}
+
+// JSONP writes Data as JSON wrapped in a call to Callback, or as plain
+// JSON if Callback is empty or not a, possibly dotted, JS identifier
+type JSONP struct {
+	Callback string
+	Data     interface{}
+}
+
+// PureJSON writes Data as JSON without escaping HTML characters
+type PureJSON struct {
+	Data interface{}
+}
+
+var (
+	jsonContentType       = []string{"application/json; charset=utf-8"}
+	javascriptContentType = []string{"application/javascript; charset=utf-8"}
+)
+
+// marshalJSON encodes obj into a buffer first, so nothing
+// is written to the response when encoding fails
+func marshalJSON(obj interface{}, escapeHTML bool) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(escapeHTML)
+	if err := enc.Encode(obj); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeJSON(w http.ResponseWriter, obj interface{}, escapeHTML bool) error {
+	b, err := marshalJSON(obj, escapeHTML)
+	if err != nil {
+		return err
+	}
+	writeContentType(w, jsonContentType)
+	_, err = w.Write(b)
+	return err
+}
+
+func (r JSON) Render(w http.ResponseWriter) error {
+	return writeJSON(w, r.Data, true)
+}
+
+func (r JSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, jsonContentType)
+}
+
+func (r IndentedJSON) Render(w http.ResponseWriter) error {
+	b, err := json.MarshalIndent(r.Data, "", "    ")
+	if err != nil {
+		return err
+	}
+	writeContentType(w, jsonContentType)
+	_, err = w.Write(b)
+	return err
+}
+
+func (r IndentedJSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, jsonContentType)
+}
+
+func (r SecureJSON) Render(w http.ResponseWriter) error {
+	b, err := marshalJSON(r.Data, true)
+	if err != nil {
+		return err
+	}
+	writeContentType(w, jsonContentType)
+	if trimmed := bytes.TrimSpace(b); bytes.HasPrefix(trimmed, []byte("[")) && bytes.HasSuffix(trimmed, []byte("]")) {
+		if _, err := w.Write([]byte(r.Prefix)); err != nil {
+			return err
+		}
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func (r SecureJSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, jsonContentType)
+}
+
+// jsonpCallback matches the callbacks JSONP calls, anything else
+// could run arbitrary script in the page including the response
+var jsonpCallback = regexp.MustCompile(`^[A-Za-z_$][\w$]*(\.[A-Za-z_$][\w$]*)*$`)
+
+func (r JSONP) Render(w http.ResponseWriter) error {
+	if !jsonpCallback.MatchString(r.Callback) {
+		return writeJSON(w, r.Data, true)
+	}
+	b, err := marshalJSON(r.Data, true)
+	if err != nil {
+		return err
+	}
+	writeContentType(w, javascriptContentType)
+	var buf bytes.Buffer
+	buf.WriteString(r.Callback)
+	buf.WriteByte('(')
+	buf.Write(bytes.TrimSpace(b))
+	buf.WriteString(");")
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func (r JSONP) WriteContentType(w http.ResponseWriter) {
+	if !jsonpCallback.MatchString(r.Callback) {
+		writeContentType(w, jsonContentType)
+		return
+	}
+	writeContentType(w, javascriptContentType)
+}
+
+func (r PureJSON) Render(w http.ResponseWriter) error {
+	return writeJSON(w, r.Data, false)
+}
+
+func (r PureJSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, jsonContentType)
+}
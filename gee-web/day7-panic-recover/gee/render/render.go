@@ -0,0 +1,37 @@
+// Package render writes the responses of gee. A Render knows the
+// Content-Type of its response and how to write the body.
+package render
+
+import "net/http"
+
+// Render is implemented by every response body gee can write
+type Render interface {
+	// Render writes the body, it must not write anything if it fails
+	// before the first byte so that the caller can answer with an error
+	Render(http.ResponseWriter) error
+	// WriteContentType sets the Content-Type header,
+	// also used for responses without a body such as HEAD or 304
+	WriteContentType(w http.ResponseWriter)
+}
+
+var (
+	_ Render = JSON{}
+	_ Render = IndentedJSON{}
+	_ Render = SecureJSON{}
+	_ Render = JSONP{}
+	_ Render = PureJSON{}
+	_ Render = XML{}
+	_ Render = String{}
+	_ Render = Data{}
+	_ Render = Reader{}
+	_ Render = Redirect{}
+	_ Render = HTML{}
+)
+
+// writeContentType sets the Content-Type unless a handler already did
+func writeContentType(w http.ResponseWriter, value []string) {
+	header := w.Header()
+	if len(header["Content-Type"]) == 0 {
+		header["Content-Type"] = value
+	}
+}
@@ -0,0 +1,88 @@
+package render
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRenders(t *testing.T) {
+	type point struct {
+		X int `xml:"x"`
+	}
+	req := httptest.NewRequest("GET", "/old", nil)
+	tests := []struct {
+		name        string
+		r           Render
+		contentType string
+		body        string
+	}{
+		{"json", JSON{H{"html": "<b>"}}, "application/json; charset=utf-8", `{"html":"\u003cb\u003e"}` + "\n"},
+		{"pure json", PureJSON{H{"html": "<b>"}}, "application/json; charset=utf-8", `{"html":"<b>"}` + "\n"},
+		{"indented json", IndentedJSON{H{"a": 1}}, "application/json; charset=utf-8", "{\n    \"a\": 1\n}"},
+		{"secure json array", SecureJSON{"while(1);", []int{1, 2}}, "application/json; charset=utf-8", "while(1);[1,2]\n"},
+		{"secure json object", SecureJSON{"while(1);", H{"a": 1}}, "application/json; charset=utf-8", `{"a":1}` + "\n"},
+		{"jsonp", JSONP{"cb", H{"a": 1}}, "application/javascript; charset=utf-8", `cb({"a":1});`},
+		{"jsonp dotted", JSONP{"app.on_data$", 1}, "application/javascript; charset=utf-8", `app.on_data$(1);`},
+		{"jsonp injected", JSONP{"alert(document.domain)//", 1}, "application/json; charset=utf-8", "1\n"},
+		{"jsonp quoted", JSONP{"x'y", 1}, "application/json; charset=utf-8", "1\n"},
+		{"jsonp trailing dot", JSONP{"app.", 1}, "application/json; charset=utf-8", "1\n"},
+		{"jsonp without callback", JSONP{"", 1}, "application/json; charset=utf-8", "1\n"},
+		{"xml", XML{point{1}}, "application/xml; charset=utf-8", "<point><x>1</x></point>"},
+		{"string", String{"%d%%", []interface{}{5}}, "text/plain; charset=utf-8", "5%"},
+		{"string without data", String{"5%%", nil}, "text/plain; charset=utf-8", "5%"},
+		{"data", Data{"image/png", []byte("png")}, "image/png", "png"},
+		{"reader", Reader{"text/csv", 3, strings.NewReader("a,b"), nil}, "text/csv", "a,b"},
+		{"redirect", Redirect{http.StatusFound, req, "/new"}, "text/html; charset=utf-8", "<a href=\"/new\">Found</a>.\n\n"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		if err := tt.r.Render(w); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != tt.contentType {
+			t.Fatalf("%s: Content-Type %q, want %q", tt.name, ct, tt.contentType)
+		}
+		if w.Body.String() != tt.body {
+			t.Fatalf("%s: body %q, want %q", tt.name, w.Body.String(), tt.body)
+		}
+	}
+}
+
+// H is a shortcut for map[string]interface{}
+type H map[string]interface{}
+
+func TestRenderErrors(t *testing.T) {
+	tests := []Render{
+		JSON{make(chan int)},
+		IndentedJSON{make(chan int)},
+		XML{make(chan int)},
+		Redirect{Code: http.StatusOK, Request: httptest.NewRequest("GET", "/", nil), Location: "/"},
+	}
+	for _, r := range tests {
+		w := httptest.NewRecorder()
+		if err := r.Render(w); err == nil {
+			t.Fatalf("%T rendered without error", r)
+		}
+		if len(w.Header()) != 0 || w.Body.Len() != 0 {
+			t.Fatalf("%T wrote %v %q after failing", r, w.Header(), w.Body.String())
+		}
+	}
+}
+
+func TestReaderHeaders(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := Reader{
+		ContentType:   "application/octet-stream",
+		ContentLength: 4,
+		Reader:        strings.NewReader("data"),
+		Headers:       map[string]string{"Content-Disposition": `attachment; filename="a.bin"`},
+	}
+	if err := r.Render(w); err != nil {
+		t.Fatal(err)
+	}
+	if w.Header().Get("Content-Length") != "4" || w.Header().Get("Content-Disposition") == "" {
+		t.Fatalf("headers %v", w.Header())
+	}
+}
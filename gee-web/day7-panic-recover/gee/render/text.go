@@ -0,0 +1,24 @@
+package render
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// String writes Format formatted with Data, like fmt.Printf
+type String struct {
+	Format string
+	Data   []interface{}
+}
+
+var plainContentType = []string{"text/plain; charset=utf-8"}
+
+func (r String) Render(w http.ResponseWriter) (err error) {
+	writeContentType(w, plainContentType)
+	_, err = fmt.Fprintf(w, r.Format, r.Data...)
+	return
+}
+
+func (r String) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, plainContentType)
+}
@@ -0,0 +1,27 @@
+package render
+
+import (
+	"encoding/xml"
+	"net/http"
+)
+
+// XML writes Data as XML
+type XML struct {
+	Data interface{}
+}
+
+var xmlContentType = []string{"application/xml; charset=utf-8"}
+
+func (r XML) Render(w http.ResponseWriter) error {
+	b, err := xml.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	writeContentType(w, xmlContentType)
+	_, err = w.Write(b)
+	return err
+}
+
+func (r XML) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, xmlContentType)
+}
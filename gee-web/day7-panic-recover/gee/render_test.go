@@ -0,0 +1,78 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestRenderFailure(t *testing.T) {
+	r := New()
+	r.GET("/", func(c *Context) {
+		c.JSON(http.StatusOK, H{"ch": make(chan int)})
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusInternalServerError || w.Body.String() != `{"message":"Internal Server Error"}`+"\n" {
+		t.Fatalf("failed JSON answered %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestRenderWithoutBody(t *testing.T) {
+	r := New()
+	r.GET("/", func(c *Context) {
+		c.JSON(http.StatusNoContent, H{"a": 1})
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusNoContent || w.Body.Len() != 0 ||
+		w.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+		t.Fatalf("204 answered with %v %q", w.Header(), w.Body.String())
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	r := New()
+	r.GET("/old", func(c *Context) {
+		c.Redirect(http.StatusMovedPermanently, "/new")
+	})
+	r.GET("/bad", func(c *Context) {
+		c.Redirect(http.StatusOK, "/new")
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/old", nil))
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/new" {
+		t.Fatalf("redirect answered %d %v", w.Code, w.Header())
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/bad", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("redirect with 200 answered %d", w.Code)
+	}
+}
+
+func TestSecureJSONAndJSONP(t *testing.T) {
+	r := New()
+	r.GET("/secure", func(c *Context) {
+		c.SecureJSON(http.StatusOK, []string{"a"})
+	})
+	r.GET("/jsonp", func(c *Context) {
+		c.JSONP(http.StatusOK, H{"a": 1})
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/secure", nil))
+	if w.Body.String() != "while(1);[\"a\"]\n" {
+		t.Fatalf("secure JSON %q", w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/jsonp?callback=cb", nil))
+	if w.Body.String() != `cb({"a":1});` {
+		t.Fatalf("JSONP %q", w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/jsonp?callback="+url.QueryEscape("alert(document.domain)//"), nil))
+	if w.Body.String() != `{"a":1}`+"\n" || w.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+		t.Fatalf("JSONP with an injected callback answered %v %q", w.Header(), w.Body.String())
+	}
+}
@@ -0,0 +1,78 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := &responseWriter{}
+	w.reset(rec)
+	if w.Written() || w.Size() != -1 || w.Status() != http.StatusOK {
+		t.Fatal("new writer should not be written with status 200")
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Header().Set("X-Late", "1")
+	if w.Written() || rec.Code != http.StatusOK {
+		t.Fatal("WriteHeader should not send the headers yet")
+	}
+	w.Write([]byte("hello"))
+	w.WriteHeader(http.StatusInternalServerError)
+	if !w.Written() || w.Size() != 5 || w.Status() != http.StatusCreated {
+		t.Fatalf("unexpected state size=%d status=%d", w.Size(), w.Status())
+	}
+	if rec.Code != http.StatusCreated || rec.Header().Get("X-Late") != "1" {
+		t.Fatal("headers set before the first write should be sent")
+	}
+
+	w.Flush()
+	if !rec.Flushed {
+		t.Fatal("Flush should pass through to http.Flusher")
+	}
+	if _, _, err := w.Hijack(); err == nil {
+		t.Fatal("Hijack should fail when the writer is not a http.Hijacker")
+	}
+}
+
+func TestWriterStatusInLogger(t *testing.T) {
+	r := New()
+	var status, size int
+	r.Use(func(c *Context) {
+		c.Next()
+		status, size = c.Writer.Status(), c.Writer.Size()
+	})
+	r.GET("/raw", func(c *Context) {
+		c.Writer.WriteHeader(http.StatusAccepted)
+		c.Writer.Write([]byte("raw"))
+	})
+	r.GET("/empty", func(c *Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/raw", nil))
+	if status != http.StatusAccepted || size != 3 {
+		t.Fatalf("writes to c.Writer should be tracked, got %d %d", status, size)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/empty", nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("pending status should be sent when the chain finishes, got %d", w.Code)
+	}
+}
+
+func TestRecoveryAfterWrite(t *testing.T) {
+	r := New()
+	r.Use(Recovery())
+	r.GET("/panic", func(c *Context) {
+		c.String(http.StatusOK, "partial")
+		panic("boom")
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/panic", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "partial" {
+		t.Fatalf("Recovery should not write after the headers went out, got %d %q", w.Code, w.Body.String())
+	}
+}
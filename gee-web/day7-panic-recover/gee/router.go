@@ -1,19 +1,22 @@
 package gee
 
 import (
+	"fmt"
 	"net/http"
+	"sort"
 	"strings"
 )
 
 type router struct {
-	roots    map[string]*node
-	handlers map[string]HandlerFunc
+	roots     map[string]*node
+	statics   map[string]map[string]*node // routes without wildcards, by clean path
+	maxParams int                         // to size the Params of new Contexts
 }
 
 func newRouter() *router {
 	return &router{
-		roots:    make(map[string]*node),
-		handlers: make(map[string]HandlerFunc),
+		roots:   make(map[string]*node),
+		statics: make(map[string]map[string]*node),
 	}
 }
 
@@ -33,44 +36,87 @@ func parsePattern(pattern string) []string {
 	return parts
 }
 
-func (r *router) addRoute(method string, pattern string, handler HandlerFunc) {
+// validatePattern rejects patterns that parsePattern would silently rewrite
+func validatePattern(pattern string) error {
+	if pattern == "" || pattern[0] != '/' {
+		return fmt.Errorf("route %q must begin with '/'", pattern)
+	}
+	vs := strings.Split(pattern, "/")
+	for i, item := range vs {
+		if item == ":" {
+			return fmt.Errorf("route %s: wildcard ':' must be named", pattern)
+		}
+		if strings.HasPrefix(item, "*") && i != len(vs)-1 {
+			return fmt.Errorf("route %s: catch-all %s must be the last segment", pattern, item)
+		}
+	}
+	return nil
+}
+
+// cleanPath returns path in the form routes are stored in the tree:
+// a leading slash, no empty segments and no trailing slash.
+// Paths already in that form are returned without allocating.
+func cleanPath(path string) string {
+	clean := len(path) > 0 && path[0] == '/' && !strings.Contains(path, "//") &&
+		(len(path) == 1 || path[len(path)-1] != '/')
+	if clean {
+		return path
+	}
+	vs := strings.Split(path, "/")
+	parts := vs[:0]
+	for _, item := range vs {
+		if item != "" {
+			parts = append(parts, item)
+		}
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+func (r *router) addRoute(method string, pattern string, handlers []HandlerFunc) (*node, error) {
+	if err := validatePattern(pattern); err != nil {
+		return nil, err
+	}
 	parts := parsePattern(pattern)
 
-	key := method + "-" + pattern
 	_, ok := r.roots[method]
 	if !ok {
 		r.roots[method] = &node{}
+		r.statics[method] = make(map[string]*node)
+	}
+	n, err := r.roots[method].insert(pattern, parts)
+	if err != nil {
+		return nil, fmt.Errorf("%s %v", method, err)
 	}
-	r.roots[method].insert(pattern, parts, 0)
-	r.handlers[key] = handler
+	n.handlers = handlers
+
+	wildcards := 0
+	for _, part := range parts {
+		if part[0] == ':' || part[0] == '*' {
+			wildcards++
+		}
+	}
+	if wildcards > r.maxParams {
+		r.maxParams = wildcards
+	}
+	if wildcards == 0 {
+		r.statics[method]["/"+strings.Join(parts, "/")] = n
+	}
+	return n, nil
 }
 
-func (r *router) getRoute(method string, path string) (*node, map[string]string) {
-	searchParts := parsePattern(path)
-	params := make(map[string]string)
+// getRoute returns the route node matching path and appends
+// the values of its wildcards to params
+func (r *router) getRoute(method string, path string, params *Params) *node {
 	root, ok := r.roots[method]
-
 	if !ok {
-		return nil, nil
+		return nil
 	}
 
-	n := root.search(searchParts, 0)
-
-	if n != nil {
-		parts := parsePattern(n.pattern)
-		for index, part := range parts {
-			if part[0] == ':' {
-				params[part[1:]] = searchParts[index]
-			}
-			if part[0] == '*' && len(part) > 1 {
-				params[part[1:]] = strings.Join(searchParts[index:], "/")
-				break
-			}
-		}
-		return n, params
+	path = cleanPath(path)
+	if n, ok := r.statics[method][path]; ok {
+		return n
 	}
-
-	return nil, nil
+	return root.search(path, params)
 }
 
 func (r *router) getRoutes(method string) []*node {
@@ -83,17 +129,64 @@ func (r *router) getRoutes(method string) []*node {
 	return nodes
 }
 
+// allowed returns the methods that have a route matching path,
+// joined for the Allow header. OPTIONS is always included because
+// it is answered automatically when no OPTIONS route matches.
+func (r *router) allowed(path string) string {
+	methods := make([]string, 0, len(r.roots)+1)
+	var params Params
+	for method := range r.roots {
+		if r.getRoute(method, path, &params) != nil || path == "*" {
+			methods = append(methods, method)
+		}
+		params = params[:0]
+	}
+	if len(methods) == 0 {
+		return ""
+	}
+	methods = append(methods, http.MethodOptions)
+	sort.Strings(methods)
+	// drop the duplicate when an OPTIONS route matched as well
+	j := 1
+	for i := 1; i < len(methods); i++ {
+		if methods[i] != methods[j-1] {
+			methods[j] = methods[i]
+			j++
+		}
+	}
+	return strings.Join(methods[:j], ", ")
+}
+
+func defaultNoRoute(c *Context) {
+	c.String(http.StatusNotFound, "404 NOT FOUND: %s\n", c.Path)
+}
+
+func defaultNoMethod(c *Context) {
+	c.String(http.StatusMethodNotAllowed, "405 METHOD NOT ALLOWED: %s\n", c.Method)
+}
+
+func defaultOptions(c *Context) {
+	c.Status(http.StatusNoContent)
+}
+
+// handle runs the handler chain resolved at registration for the matched
+// route. Unmatched requests run the NoRoute or NoMethod handlers behind
+// the global middlewares.
 func (r *router) handle(c *Context) {
-	n, params := r.getRoute(c.Method, c.Path)
+	n := r.getRoute(c.Method, c.Path, &c.Params)
 
 	if n != nil {
-		key := c.Method + "-" + n.pattern
-		c.Params = params
-		c.handlers = append(c.handlers, r.handlers[key])
+		c.handlers = n.handlers
+		c.fullPath = n.pattern
+	} else if allow := r.allowed(c.Path); allow != "" {
+		c.SetHeader("Allow", allow)
+		if c.Method == http.MethodOptions {
+			c.handlers = c.engine.allOptions
+		} else {
+			c.handlers = c.engine.allNoMethod
+		}
 	} else {
-		c.handlers = append(c.handlers, func(c *Context) {
-			c.String(http.StatusNotFound, "404 NOT FOUND: %s\n", c.Path)
-		})
+		c.handlers = c.engine.allNoRoute
 	}
 	c.Next()
 }
@@ -0,0 +1,196 @@
+package gee
+
+import (
+	"strings"
+	"testing"
+)
+
+// legacyRouter is the segment trie that the radix tree replaced,
+// kept here so the benchmarks can be compared side by side
+type legacyRouter struct {
+	roots    map[string]*legacyNode
+	handlers map[string]HandlerFunc
+}
+
+type legacyNode struct {
+	pattern  string
+	part     string
+	children []*legacyNode
+	isWild   bool
+}
+
+func newLegacyRouter() *legacyRouter {
+	return &legacyRouter{
+		roots:    make(map[string]*legacyNode),
+		handlers: make(map[string]HandlerFunc),
+	}
+}
+
+func (r *legacyRouter) addRoute(method string, pattern string, handler HandlerFunc) {
+	parts := parsePattern(pattern)
+	if _, ok := r.roots[method]; !ok {
+		r.roots[method] = &legacyNode{}
+	}
+	r.roots[method].insert(pattern, parts, 0)
+	r.handlers[method+"-"+pattern] = handler
+}
+
+func (r *legacyRouter) getRoute(method string, path string) (*legacyNode, map[string]string) {
+	searchParts := parsePattern(path)
+	params := make(map[string]string)
+	root, ok := r.roots[method]
+	if !ok {
+		return nil, nil
+	}
+	n := root.search(searchParts, 0)
+	if n == nil {
+		return nil, nil
+	}
+	parts := parsePattern(n.pattern)
+	for index, part := range parts {
+		if part[0] == ':' {
+			params[part[1:]] = searchParts[index]
+		}
+		if part[0] == '*' && len(part) > 1 {
+			params[part[1:]] = strings.Join(searchParts[index:], "/")
+			break
+		}
+	}
+	return n, params
+}
+
+func (n *legacyNode) insert(pattern string, parts []string, height int) {
+	if len(parts) == height {
+		n.pattern = pattern
+		return
+	}
+	part := parts[height]
+	var child *legacyNode
+	for _, c := range n.children {
+		if c.part == part || c.isWild {
+			child = c
+			break
+		}
+	}
+	if child == nil {
+		child = &legacyNode{part: part, isWild: part[0] == ':' || part[0] == '*'}
+		n.children = append(n.children, child)
+	}
+	child.insert(pattern, parts, height+1)
+}
+
+func (n *legacyNode) search(parts []string, height int) *legacyNode {
+	if len(parts) == height || strings.HasPrefix(n.part, "*") {
+		if n.pattern == "" {
+			return nil
+		}
+		return n
+	}
+	part := parts[height]
+	children := make([]*legacyNode, 0)
+	for _, child := range n.children {
+		if child.part == part || child.isWild {
+			children = append(children, child)
+		}
+	}
+	for _, child := range children {
+		if result := child.search(parts, height+1); result != nil {
+			return result
+		}
+	}
+	return nil
+}
+
+var benchRoutes = []string{
+	"/",
+	"/about",
+	"/users",
+	"/users/:id",
+	"/users/:id/posts",
+	"/users/:id/posts/:post",
+	"/repos/:owner/:repo",
+	"/repos/:owner/:repo/issues",
+	"/static/*filepath",
+}
+
+const (
+	benchStaticPath = "/users"
+	benchParamPath  = "/users/42/posts/7"
+)
+
+func BenchmarkRouterStatic(b *testing.B) {
+	r := newRouter()
+	for _, pattern := range benchRoutes {
+		r.addRoute("GET", pattern, nil)
+	}
+	params := make(Params, 0, 8)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		params = params[:0]
+		if r.getRoute("GET", benchStaticPath, &params) == nil {
+			b.Fatal("route not found")
+		}
+	}
+}
+
+func BenchmarkRouterParam(b *testing.B) {
+	r := newRouter()
+	for _, pattern := range benchRoutes {
+		r.addRoute("GET", pattern, nil)
+	}
+	params := make(Params, 0, 8)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		params = params[:0]
+		if r.getRoute("GET", benchParamPath, &params) == nil {
+			b.Fatal("route not found")
+		}
+	}
+}
+
+func BenchmarkLegacyRouterStatic(b *testing.B) {
+	r := newLegacyRouter()
+	for _, pattern := range benchRoutes {
+		r.addRoute("GET", pattern, nil)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if n, _ := r.getRoute("GET", benchStaticPath); n == nil {
+			b.Fatal("route not found")
+		}
+	}
+}
+
+func BenchmarkLegacyRouterParam(b *testing.B) {
+	r := newLegacyRouter()
+	for _, pattern := range benchRoutes {
+		r.addRoute("GET", pattern, nil)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if n, _ := r.getRoute("GET", benchParamPath); n == nil {
+			b.Fatal("route not found")
+		}
+	}
+}
+
+func TestRouterZeroAllocs(t *testing.T) {
+	r := newRouter()
+	for _, pattern := range benchRoutes {
+		r.addRoute("GET", pattern, nil)
+	}
+	params := make(Params, 0, 8)
+	for _, path := range []string{benchStaticPath, benchParamPath, "/static/css/app.css"} {
+		allocs := testing.AllocsPerRun(100, func() {
+			params = params[:0]
+			r.getRoute("GET", path, &params)
+		})
+		if allocs != 0 {
+			t.Fatalf("%s: getRoute should not allocate, got %v allocs", path, allocs)
+		}
+	}
+}
@@ -27,7 +27,8 @@ func TestParsePattern(t *testing.T) {
 
 func TestGetRoute(t *testing.T) {
 	r := newTestRouter()
-	n, ps := r.getRoute("GET", "/hello/geektutu")
+	var ps Params
+	n := r.getRoute("GET", "/hello/geektutu", &ps)
 
 	if n == nil {
 		t.Fatal("nil shouldn't be returned")
@@ -37,24 +38,26 @@ func TestGetRoute(t *testing.T) {
 		t.Fatal("should match /hello/:name")
 	}
 
-	if ps["name"] != "geektutu" {
+	if ps.ByName("name") != "geektutu" {
 		t.Fatal("name should be equal to 'geektutu'")
 	}
 
-	fmt.Printf("matched path: %s, params['name']: %s\n", n.pattern, ps["name"])
+	fmt.Printf("matched path: %s, params['name']: %s\n", n.pattern, ps.ByName("name"))
 
 }
 
 func TestGetRoute2(t *testing.T) {
 	r := newTestRouter()
-	n1, ps1 := r.getRoute("GET", "/assets/file1.txt")
-	ok1 := n1.pattern == "/assets/*filepath" && ps1["filepath"] == "file1.txt"
+	var ps1 Params
+	n1 := r.getRoute("GET", "/assets/file1.txt", &ps1)
+	ok1 := n1.pattern == "/assets/*filepath" && ps1.ByName("filepath") == "file1.txt"
 	if !ok1 {
 		t.Fatal("pattern shoule be /assets/*filepath & filepath shoule be file1.txt")
 	}
 
-	n2, ps2 := r.getRoute("GET", "/assets/css/test.css")
-	ok2 := n2.pattern == "/assets/*filepath" && ps2["filepath"] == "css/test.css"
+	var ps2 Params
+	n2 := r.getRoute("GET", "/assets/css/test.css", &ps2)
+	ok2 := n2.pattern == "/assets/*filepath" && ps2.ByName("filepath") == "css/test.css"
 	if !ok2 {
 		t.Fatal("pattern shoule be /assets/*filepath & filepath shoule be css/test.css")
 	}
@@ -72,3 +75,141 @@ func TestGetRoutes(t *testing.T) {
 		t.Fatal("the number of routes shoule be 4")
 	}
 }
+
+func TestAddRouteConflict(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []string
+		pattern  string
+	}{
+		{"duplicate static", []string{"/user/new"}, "/user/new"},
+		{"duplicate after cleaning", []string{"/user/new"}, "/user//new"},
+		{"duplicate param", []string{"/user/:id"}, "/user/:id"},
+		{"param name", []string{"/user/:id"}, "/user/:name/profile"},
+		{"param name at leaf", []string{"/user/:id/profile"}, "/user/:name"},
+		{"catch-all name", []string{"/static/*filepath"}, "/static/*path"},
+		{"catch-all not last", nil, "/static/*filepath/raw"},
+		{"unnamed param", nil, "/user/:"},
+		{"missing leading slash", nil, "user/:id"},
+	}
+	for _, tt := range tests {
+		r := newRouter()
+		for _, pattern := range tt.existing {
+			if _, err := r.addRoute("GET", pattern, nil); err != nil {
+				t.Fatalf("%s: unexpected error registering %s: %v", tt.name, pattern, err)
+			}
+		}
+		if _, err := r.addRoute("GET", tt.pattern, nil); err == nil {
+			t.Fatalf("%s: registering %s should fail", tt.name, tt.pattern)
+		}
+		// the same pattern under another method never conflicts
+		if len(tt.existing) > 0 {
+			if _, err := r.addRoute("POST", tt.existing[0], nil); err != nil {
+				t.Fatalf("%s: POST %s should not conflict: %v", tt.name, tt.existing[0], err)
+			}
+		}
+	}
+}
+
+func TestGroupPanicsOnConflict(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("ambiguous route should panic")
+		}
+	}()
+	r := New()
+	r.GET("/user/:id", nil)
+	r.GET("/user/:name/profile", nil)
+}
+
+func TestRoutePriority(t *testing.T) {
+	patterns := []string{"/user/*path", "/user/:id/profile", "/user/:id", "/user/new", "/user/new/edit"}
+	tests := []struct {
+		path    string
+		pattern string
+	}{
+		{"/user/new", "/user/new"},
+		{"/user/new/edit", "/user/new/edit"},
+		{"/user/new/profile", "/user/:id/profile"},
+		{"/user/42", "/user/:id"},
+		{"/user/42/profile", "/user/:id/profile"},
+		{"/user/42/posts", "/user/*path"},
+		{"/user/new/edit/x", "/user/*path"},
+	}
+	// every registration order must give the same result
+	for i := range patterns {
+		r := newRouter()
+		for j := range patterns {
+			pattern := patterns[(i+j)%len(patterns)]
+			if _, err := r.addRoute("GET", pattern, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, tt := range tests {
+			var ps Params
+			n := r.getRoute("GET", tt.path, &ps)
+			if n == nil || n.pattern != tt.pattern {
+				t.Fatalf("order %d: %s should match %s, got %v", i, tt.path, tt.pattern, n)
+			}
+		}
+	}
+}
+
+func TestGetRouteParams(t *testing.T) {
+	r := newRouter()
+	r.addRoute("GET", "/repos/:owner/:repo/issues/:number", nil)
+	r.addRoute("GET", "/repos/:owner/:repo/contents/*path", nil)
+	r.addRoute("GET", "/repos/:owner/:repo", nil)
+	r.addRoute("GET", "/users", nil)
+	r.addRoute("GET", "/user/:id", nil)
+
+	tests := []struct {
+		path    string
+		pattern string
+		params  Params
+	}{
+		{"/repos/geektutu/gee/issues/7", "/repos/:owner/:repo/issues/:number",
+			Params{{"owner", "geektutu"}, {"repo", "gee"}, {"number", "7"}}},
+		{"/repos/geektutu/gee/contents/a/b.go", "/repos/:owner/:repo/contents/*path",
+			Params{{"owner", "geektutu"}, {"repo", "gee"}, {"path", "a/b.go"}}},
+		{"/repos/geektutu/gee/", "/repos/:owner/:repo",
+			Params{{"owner", "geektutu"}, {"repo", "gee"}}},
+		{"//users/", "/users", Params{}},
+		{"/user/1", "/user/:id", Params{{"id", "1"}}},
+		{"/users/1", "", Params{}},
+		{"/repos/geektutu/gee/contents", "", Params{}},
+	}
+	for _, tt := range tests {
+		ps := Params{}
+		n := r.getRoute("GET", tt.path, &ps)
+		if tt.pattern == "" {
+			if n != nil {
+				t.Fatalf("%s shouldn't match, got %s", tt.path, n.pattern)
+			}
+			continue
+		}
+		if n == nil || n.pattern != tt.pattern {
+			t.Fatalf("%s should match %s, got %v", tt.path, tt.pattern, n)
+		}
+		if !reflect.DeepEqual(ps, tt.params) {
+			t.Fatalf("%s: params should be %v, got %v", tt.path, tt.params, ps)
+		}
+	}
+}
+
+func TestCleanPath(t *testing.T) {
+	tests := map[string]string{
+		"":          "/",
+		"/":         "/",
+		"//":        "/",
+		"/a/b":      "/a/b",
+		"/a/b/":     "/a/b",
+		"a//b":      "/a/b",
+		"/a//b///c": "/a/b/c",
+	}
+	for path, want := range tests {
+		if got := cleanPath(path); got != want {
+			t.Fatalf("cleanPath(%q) should be %q, got %q", path, want, got)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package gee
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const defaultShutdownTimeout = 10 * time.Second
+
+// lifecycle tracks the servers started by the Run methods,
+// so that Shutdown can drain all of them
+type lifecycle struct {
+	mu           sync.Mutex
+	servers      []*http.Server
+	shuttingDown bool
+	stopped      chan struct{} // closed once Shutdown finished
+	startOnce    sync.Once
+	startErr     error
+	onStart      []func() error
+	onShutdown   []func() error
+}
+
+// OnStart registers hooks that run once before the first server
+// starts serving, an error aborts the start
+func (engine *Engine) OnStart(hooks ...func() error) {
+	engine.lifecycle.onStart = append(engine.lifecycle.onStart, hooks...)
+}
+
+// OnShutdown registers hooks that run after Shutdown drained the
+// in-flight requests, e.g. to close database pools
+func (engine *Engine) OnShutdown(hooks ...func() error) {
+	engine.lifecycle.onShutdown = append(engine.lifecycle.onShutdown, hooks...)
+}
+
+// Run defines the method to start a http server
+func (engine *Engine) Run(addr string) (err error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return engine.serve(&http.Server{Addr: addr}, l, "", "")
+}
+
+// RunTLS starts a https server with the certificate and key files
+func (engine *Engine) RunTLS(addr, certFile, keyFile string) (err error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return engine.serve(&http.Server{Addr: addr}, l, certFile, keyFile)
+}
+
+// RunListener starts a http server on the given listener
+func (engine *Engine) RunListener(l net.Listener) (err error) {
+	return engine.serve(&http.Server{Addr: l.Addr().String()}, l, "", "")
+}
+
+// RunUnix starts a http server on the unix socket file,
+// a stale socket left by a previous run is removed first
+func (engine *Engine) RunUnix(file string) (err error) {
+	if fi, err := os.Stat(file); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		os.Remove(file)
+	}
+	l, err := net.Listen("unix", file)
+	if err != nil {
+		return err
+	}
+	return engine.serve(&http.Server{}, l, "", "")
+}
+
+// RunContext starts a http server and shuts it down gracefully
+// once ctx is done, e.g. on SIGTERM with signal.NotifyContext
+func (engine *Engine) RunContext(ctx context.Context, addr string) (err error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- engine.RunListener(l)
+	}()
+	select {
+	case err = <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	if err = engine.Shutdown(context.Background()); err != nil {
+		return err
+	}
+	return <-errCh
+}
+
+// serve runs the OnStart hooks once and serves on l until the server
+// fails or Shutdown completes. It returns nil after a graceful shutdown.
+func (engine *Engine) serve(srv *http.Server, l net.Listener, certFile, keyFile string) error {
+	lc := &engine.lifecycle
+	srv.Handler = engine
+
+	lc.startOnce.Do(func() {
+		for _, hook := range lc.onStart {
+			if lc.startErr = hook(); lc.startErr != nil {
+				return
+			}
+		}
+	})
+	lc.mu.Lock()
+	if lc.startErr != nil || lc.shuttingDown {
+		lc.mu.Unlock()
+		l.Close()
+		if lc.startErr != nil {
+			return lc.startErr
+		}
+		return http.ErrServerClosed
+	}
+	lc.servers = append(lc.servers, srv)
+	lc.mu.Unlock()
+
+	var err error
+	if certFile != "" || keyFile != "" {
+		err = srv.ServeTLS(l, certFile, keyFile)
+	} else {
+		err = srv.Serve(l)
+	}
+	if err == http.ErrServerClosed {
+		// Serve returns as soon as Shutdown starts, wait for the drain
+		<-lc.stopped
+		return nil
+	}
+	return err
+}
+
+// Shutdown gracefully stops all servers started by the Run methods: they
+// stop accepting connections and in-flight requests are drained, bounded
+// by ctx or else by ShutdownTimeout. Then the OnShutdown hooks run.
+// An Engine cannot be started again after Shutdown.
+func (engine *Engine) Shutdown(ctx context.Context) error {
+	lc := &engine.lifecycle
+	lc.mu.Lock()
+	if lc.shuttingDown {
+		lc.mu.Unlock()
+		<-lc.stopped
+		return errors.New("gee: engine is already shut down")
+	}
+	lc.shuttingDown = true
+	servers := lc.servers
+	lc.mu.Unlock()
+	defer close(lc.stopped)
+
+	if _, ok := ctx.Deadline(); !ok && engine.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, engine.ShutdownTimeout)
+		defer cancel()
+	}
+
+	var firstErr error
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			// the timeout expired, drop the remaining connections
+			srv.Close()
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	for _, hook := range lc.onShutdown {
+		if err := hook(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
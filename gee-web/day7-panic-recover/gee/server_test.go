@@ -0,0 +1,226 @@
+package gee
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeSelfSignedCert writes a certificate for 127.0.0.1 and its key to dir
+func writeSelfSignedCert(t *testing.T, dir string) (certFile, keyFile string, pool *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "gee test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	ioutil.WriteFile(certFile, certPem, 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	pool = x509.NewCertPool()
+	pool.AppendCertsFromPEM(certPem)
+	return
+}
+
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+// testDrain starts the engine with run, sends a slow request through client
+// and checks that Shutdown waits for it before the OnShutdown hooks run
+func testDrain(t *testing.T, run func(*Engine) error, client *http.Client, url string) {
+	r := New()
+	started, release := make(chan struct{}), make(chan struct{})
+	r.GET("/slow", func(c *Context) {
+		close(started)
+		<-release
+		c.String(http.StatusOK, "done")
+	})
+	r.GET("/ping", func(c *Context) {})
+	var hooks []string
+	r.OnStart(func() error {
+		hooks = append(hooks, "start")
+		return nil
+	})
+	r.OnShutdown(func() error {
+		hooks = append(hooks, "shutdown")
+		return nil
+	})
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- run(r) }()
+	// wait for the server to accept connections
+	for i := 0; ; i++ {
+		resp, err := client.Get(url + "/ping")
+		if err == nil {
+			resp.Body.Close()
+			break
+		}
+		if i == 100 {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	respErr := make(chan error, 1)
+	go func() {
+		resp, err := client.Get(url + "/slow")
+		if err == nil {
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if string(body) != "done" {
+				t.Errorf("in-flight request should complete, got %q", body)
+			}
+		}
+		respErr <- err
+	}()
+	<-started
+
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- r.Shutdown(context.Background()) }()
+	select {
+	case <-shutdownErr:
+		t.Fatal("Shutdown should wait for in-flight requests")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+
+	if err := <-respErr; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-shutdownErr; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-runErr; err != nil {
+		t.Fatalf("Run should return nil after Shutdown, got %v", err)
+	}
+	if len(hooks) != 2 || hooks[0] != "start" || hooks[1] != "shutdown" {
+		t.Fatalf("hooks should run on start and shutdown, got %v", hooks)
+	}
+}
+
+func TestRunTLSShutdown(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "gee")
+	defer os.RemoveAll(dir)
+	certFile, keyFile, pool := writeSelfSignedCert(t, dir)
+	addr := freeAddr(t)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	testDrain(t, func(r *Engine) error {
+		return r.RunTLS(addr, certFile, keyFile)
+	}, client, "https://"+addr)
+}
+
+func TestRunUnixShutdown(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "gee")
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "gee.sock")
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", file)
+		},
+	}}
+	testDrain(t, func(r *Engine) error {
+		return r.RunUnix(file)
+	}, client, "http://gee")
+}
+
+func TestRunContext(t *testing.T) {
+	r := New()
+	var closed bool
+	r.OnShutdown(func() error {
+		closed = true
+		return nil
+	})
+	r.GET("/ping", func(c *Context) {
+		c.String(http.StatusOK, "pong")
+	})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- r.RunContext(ctx, addr) }()
+	// poll until the server answers, starting it may take a while
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		resp, err := http.Get("http://" + addr + "/ping")
+		if err == nil {
+			resp.Body.Close()
+			break
+		}
+		select {
+		case err := <-done:
+			t.Fatalf("RunContext failed: %v", err)
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the server did not start: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil || !closed {
+		t.Fatalf("RunContext should shut down when ctx is done, err=%v closed=%t", err, closed)
+	}
+	if err := r.Run("127.0.0.1:0"); err != http.ErrServerClosed {
+		t.Fatalf("Run after Shutdown should fail, got %v", err)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	r := New()
+	r.ShutdownTimeout = 20 * time.Millisecond
+	started := make(chan struct{})
+	r.GET("/hang", func(c *Context) {
+		close(started)
+		<-c.Done()
+	})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go r.RunListener(l)
+	go http.Get("http://" + l.Addr().String() + "/hang")
+	<-started
+	if err := r.Shutdown(context.Background()); err != context.DeadlineExceeded {
+		t.Fatalf("Shutdown should give up after ShutdownTimeout, got %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package sessions
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrInvalidCookie is returned for cookies that fail the signature
+	// check or can't be decrypted, e.g. tampered ones
+	ErrInvalidCookie = errors.New("sessions: invalid cookie")
+	// ErrExpiredCookie is returned for cookies older than their MaxAge
+	ErrExpiredCookie = errors.New("sessions: expired cookie")
+	// ErrCookieTooLong is returned by Save when the encoded
+	// cookie exceeds what browsers store
+	ErrCookieTooLong = errors.New("sessions: the encoded cookie is too long")
+)
+
+func init() {
+	// flash messages are stored as a slice
+	gob.Register([]interface{}{})
+}
+
+// maxCookieLength is the size browsers are required to store
+const maxCookieLength = 4096
+
+// codec signs cookie values with HMAC-SHA256 and encrypts them
+// with AES-GCM if it has an encryption key
+type codec struct {
+	hashKey []byte
+	aead    cipher.AEAD
+}
+
+// newCodecs creates codecs from pairs of hash and encryption keys, the
+// first pair encodes and all of them decode, so keys can be rotated by
+// prepending a new pair. The encryption key of a pair may be nil.
+func newCodecs(keyPairs [][]byte) []codec {
+	if len(keyPairs) == 0 {
+		panic("sessions: no hash key")
+	}
+	codecs := make([]codec, 0, (len(keyPairs)+1)/2)
+	for i := 0; i < len(keyPairs); i += 2 {
+		if len(keyPairs[i]) == 0 {
+			panic("sessions: empty hash key")
+		}
+		c := codec{hashKey: keyPairs[i]}
+		if i+1 < len(keyPairs) && len(keyPairs[i+1]) > 0 {
+			block, err := aes.NewCipher(keyPairs[i+1])
+			if err != nil {
+				panic(fmt.Sprintf("sessions: encryption key: %v", err))
+			}
+			if c.aead, err = cipher.NewGCM(block); err != nil {
+				panic(fmt.Sprintf("sessions: encryption key: %v", err))
+			}
+		}
+		codecs = append(codecs, c)
+	}
+	return codecs
+}
+
+func (c codec) mac(name string, payload []byte) []byte {
+	h := hmac.New(sha256.New, c.hashKey)
+	h.Write([]byte(name))
+	h.Write([]byte{'|'})
+	h.Write(payload)
+	return h.Sum(nil)
+}
+
+// encode returns value as a cookie value for the cookie name,
+// the name is authenticated as well so values can't be swapped
+func (c codec) encode(name string, value []byte, now time.Time) (string, error) {
+	if c.aead != nil {
+		nonce := make([]byte, c.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+		value = c.aead.Seal(nonce, nonce, value, []byte(name))
+	}
+	payload := []byte(strconv.FormatInt(now.Unix(), 10) + "|" + base64.RawURLEncoding.EncodeToString(value))
+	payload = append(payload, c.mac(name, payload)...)
+	return base64.RawURLEncoding.EncodeToString(payload), nil
+}
+
+// decode verifies and decodes a cookie value made by encode,
+// values older than maxAge seconds are rejected if maxAge > 0
+func (c codec) decode(name, cookie string, maxAge int, now time.Time) ([]byte, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cookie)
+	if err != nil || len(raw) <= sha256.Size {
+		return nil, ErrInvalidCookie
+	}
+	payload, mac := raw[:len(raw)-sha256.Size], raw[len(raw)-sha256.Size:]
+	if !hmac.Equal(mac, c.mac(name, payload)) {
+		return nil, ErrInvalidCookie
+	}
+	i := bytes.IndexByte(payload, '|')
+	if i < 0 {
+		return nil, ErrInvalidCookie
+	}
+	ts, err := strconv.ParseInt(string(payload[:i]), 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCookie
+	}
+	if maxAge > 0 && now.Unix()-ts > int64(maxAge) {
+		return nil, ErrExpiredCookie
+	}
+	value, err := base64.RawURLEncoding.DecodeString(string(payload[i+1:]))
+	if err != nil {
+		return nil, ErrInvalidCookie
+	}
+	if c.aead != nil {
+		size := c.aead.NonceSize()
+		if len(value) < size {
+			return nil, ErrInvalidCookie
+		}
+		if value, err = c.aead.Open(nil, value[:size], value[size:], []byte(name)); err != nil {
+			return nil, ErrInvalidCookie
+		}
+	}
+	return value, nil
+}
+
+// encodeCookie encodes value with the first codec
+func encodeCookie(codecs []codec, name string, value interface{}, now time.Time) (string, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+		return "", err
+	}
+	s, err := codecs[0].encode(name, buf.Bytes(), now)
+	if err != nil {
+		return "", err
+	}
+	if len(s) > maxCookieLength {
+		return "", ErrCookieTooLong
+	}
+	return s, nil
+}
+
+// decodeCookie decodes cookie into dst with the first codec that accepts it
+func decodeCookie(codecs []codec, name, cookie string, dst interface{}, maxAge int, now time.Time) error {
+	err := ErrInvalidCookie
+	for _, c := range codecs {
+		var b []byte
+		if b, err = c.decode(name, cookie, maxAge, now); err == nil {
+			return gob.NewDecoder(bytes.NewReader(b)).Decode(dst)
+		}
+		if err == ErrExpiredCookie {
+			return err
+		}
+	}
+	return err
+}
@@ -0,0 +1,58 @@
+package sessions
+
+import (
+	"net/http"
+	"time"
+)
+
+// CookieStore keeps the session values in the cookie itself,
+// signed and, with an encryption key, encrypted. The values must
+// fit into the 4KB of a cookie.
+type CookieStore struct {
+	Options Options // default options of new sessions
+	codecs  []codec
+	now     func() time.Time
+}
+
+// NewCookieStore returns a CookieStore using pairs of a hash key and
+// an encryption key. The hash key should be 32 or 64 bytes, the
+// encryption key 16, 24 or 32 bytes to select AES-128, AES-192 or
+// AES-256, or nil to only sign the values. Older pairs may follow the
+// first one, they are still accepted when reading cookies.
+func NewCookieStore(keyPairs ...[]byte) *CookieStore {
+	return &CookieStore{
+		Options: DefaultOptions,
+		codecs:  newCodecs(keyPairs),
+		now:     time.Now,
+	}
+}
+
+// Get decodes the session from the cookie name of r
+func (s *CookieStore) Get(r *http.Request, name string) (*Data, error) {
+	data := &Data{Values: make(map[string]interface{}), Options: s.Options, IsNew: true}
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return data, nil
+	}
+	values := make(map[string]interface{})
+	if err := decodeCookie(s.codecs, name, cookie.Value, &values, s.Options.MaxAge, s.now()); err != nil {
+		return data, err
+	}
+	data.Values, data.IsNew = values, false
+	return data, nil
+}
+
+// Save encodes the session into the cookie name
+func (s *CookieStore) Save(w http.ResponseWriter, name string, data *Data) error {
+	now := s.now()
+	if data.Options.MaxAge < 0 {
+		http.SetCookie(w, data.Options.newCookie(name, "", now))
+		return nil
+	}
+	value, err := encodeCookie(s.codecs, name, data.Values, now)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, data.Options.newCookie(name, value, now))
+	return nil
+}
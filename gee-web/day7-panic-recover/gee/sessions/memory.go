@@ -0,0 +1,151 @@
+package sessions
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps the session values in memory, the cookie only
+// carries the signed session id. Sessions expire after the MaxAge
+// of their options, or after TTL for browser sessions.
+type MemoryStore struct {
+	Options Options       // default options of new sessions
+	TTL     time.Duration // lifetime of sessions with MaxAge 0
+
+	codecs    []codec
+	now       func() time.Time
+	mu        sync.Mutex
+	sessions  map[string]memorySession
+	nextSweep time.Time
+}
+
+type memorySession struct {
+	values  map[string]interface{}
+	expires time.Time
+}
+
+// NewMemoryStore returns a MemoryStore, the key pairs sign and
+// optionally encrypt the session id, see NewCookieStore
+func NewMemoryStore(keyPairs ...[]byte) *MemoryStore {
+	return &MemoryStore{
+		Options:  DefaultOptions,
+		TTL:      24 * time.Hour,
+		codecs:   newCodecs(keyPairs),
+		now:      time.Now,
+		sessions: make(map[string]memorySession),
+	}
+}
+
+// Get looks up the session whose id is in the cookie name of r
+func (s *MemoryStore) Get(r *http.Request, name string) (*Data, error) {
+	data := &Data{Values: make(map[string]interface{}), Options: s.Options, IsNew: true}
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return data, nil
+	}
+	var id string
+	if err := decodeCookie(s.codecs, name, cookie.Value, &id, 0, s.now()); err != nil {
+		return data, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[id]
+	if !ok || !s.now().Before(session.expires) {
+		delete(s.sessions, id)
+		return data, ErrExpiredCookie
+	}
+	// handlers get a copy, so concurrent requests don't share the map
+	for k, v := range session.values {
+		data.Values[k] = v
+	}
+	data.ID, data.IsNew = id, false
+	return data, nil
+}
+
+// Save stores the session and sets the cookie name to its id
+func (s *MemoryStore) Save(w http.ResponseWriter, name string, data *Data) error {
+	now := s.now()
+	if data.Options.MaxAge < 0 {
+		s.mu.Lock()
+		delete(s.sessions, data.ID)
+		s.mu.Unlock()
+		data.ID = ""
+		http.SetCookie(w, data.Options.newCookie(name, "", now))
+		return nil
+	}
+
+	ttl := s.TTL
+	if data.Options.MaxAge > 0 {
+		ttl = time.Duration(data.Options.MaxAge) * time.Second
+	}
+	values := make(map[string]interface{}, len(data.Values))
+	for k, v := range data.Values {
+		values[k] = v
+	}
+	s.mu.Lock()
+	if data.ID != "" {
+		// a session deleted by another request, e.g. a logout,
+		// must not be brought back
+		session, ok := s.sessions[data.ID]
+		if (!ok || !now.Before(session.expires)) && !data.IsNew {
+			s.mu.Unlock()
+			return ErrSessionDeleted
+		}
+		if data.Renew {
+			delete(s.sessions, data.ID)
+			data.ID = ""
+		}
+	}
+	if data.ID == "" {
+		id, err := newSessionID()
+		if err != nil {
+			s.mu.Unlock()
+			return err
+		}
+		data.ID = id
+	}
+	data.Renew = false
+	s.sweep(now)
+	s.sessions[data.ID] = memorySession{values: values, expires: now.Add(ttl)}
+	s.mu.Unlock()
+
+	value, err := encodeCookie(s.codecs, name, data.ID, now)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, data.Options.newCookie(name, value, now))
+	return nil
+}
+
+// Len returns the number of stored sessions, expired ones included
+// until they are evicted
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+// sweep evicts the expired sessions, at most once a minute
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Before(s.nextSweep) {
+		return
+	}
+	s.nextSweep = now.Add(time.Minute)
+	for id, session := range s.sessions {
+		if !now.Before(session.expires) {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,204 @@
+// Package sessions keeps per-client state across requests of gee, either
+// in signed and optionally encrypted cookies or in memory on the server.
+//
+//	r.Use(sessions.Sessions("session", sessions.NewCookieStore(hashKey, encKey)))
+//	r.POST("/login", func(c *gee.Context) {
+//		s := sessions.Default(c)
+//		s.Set("user", "geektutu")
+//		s.Save()
+//		c.String(http.StatusOK, "welcome")
+//	})
+package sessions
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"gee"
+)
+
+// DefaultKey is the context key the Session is stored with
+const DefaultKey = "gee/sessions"
+
+// flashKey is the value key of the flash messages
+const flashKey = "_flash"
+
+// ErrHeadersWritten is returned by Save once the response headers
+// are sent, the cookie can't be set anymore
+var ErrHeadersWritten = errors.New("sessions: save after the response headers were written")
+
+// ErrSessionDeleted is returned by Save for a session another
+// request deleted or that expired since it was loaded
+var ErrSessionDeleted = errors.New("sessions: the session was deleted")
+
+// Options are the attributes of the session cookie. MaxAge is in
+// seconds, 0 makes a browser session and < 0 deletes the session.
+type Options struct {
+	Path     string
+	Domain   string
+	MaxAge   int
+	Secure   bool
+	HttpOnly bool
+	SameSite http.SameSite
+}
+
+// DefaultOptions are the options stores start with
+var DefaultOptions = Options{
+	Path:     "/",
+	MaxAge:   86400 * 30,
+	HttpOnly: true,
+	SameSite: http.SameSiteLaxMode,
+}
+
+// newCookie returns the cookie named name with the options applied
+func (o Options) newCookie(name, value string, now time.Time) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     o.Path,
+		Domain:   o.Domain,
+		MaxAge:   o.MaxAge,
+		Secure:   o.Secure,
+		HttpOnly: o.HttpOnly,
+		SameSite: o.SameSite,
+	}
+	if o.MaxAge > 0 {
+		cookie.Expires = now.Add(time.Duration(o.MaxAge) * time.Second)
+	} else if o.MaxAge < 0 {
+		cookie.Value = ""
+		cookie.Expires = time.Unix(1, 0)
+	}
+	return cookie
+}
+
+// Data is the state of a session as kept by a Store
+type Data struct {
+	ID      string // set by stores keeping the values on the server
+	Values  map[string]interface{}
+	Options Options
+	IsNew   bool
+	// Renew asks stores keeping the values on the server to move them
+	// to a new ID on Save, and to drop the old one
+	Renew bool
+}
+
+// Store loads and saves sessions. Values are encoded with encoding/gob,
+// custom types must be registered with gob.Register.
+type Store interface {
+	// Get returns the session name of the request. A new session is
+	// returned if there is none, along with an error if it is invalid.
+	Get(r *http.Request, name string) (*Data, error)
+	// Save stores data and sets the session cookie on w,
+	// the session is deleted if its MaxAge is < 0
+	Save(w http.ResponseWriter, name string, data *Data) error
+}
+
+// Session is the session of a request, loaded on first use
+type Session struct {
+	*session
+	c *gee.Context
+}
+
+// session is the state of a Session kept in the Keys of the context.
+// It must not hold the context, copies of the context share it.
+type session struct {
+	name  string
+	store Store
+	data  *Data
+}
+
+// Sessions loads the session name from store for every request,
+// handlers get it with Default
+func Sessions(name string, store Store) gee.HandlerFunc {
+	return func(c *gee.Context) {
+		c.Set(DefaultKey, &session{name: name, store: store})
+		c.Next()
+	}
+}
+
+// Default returns the session of the request set up by Sessions,
+// it reads and writes the cookie of c
+func Default(c *gee.Context) *Session {
+	return &Session{session: c.MustGet(DefaultKey).(*session), c: c}
+}
+
+// load gets the session from the store, invalid sessions,
+// e.g. tampered or expired ones, are replaced by a new one
+func (s *Session) load() *Data {
+	if s.data == nil {
+		s.data, _ = s.store.Get(s.c.Req, s.name)
+	}
+	return s.data
+}
+
+// ID returns the id of a server side session, empty until it is saved
+func (s *Session) ID() string {
+	return s.load().ID
+}
+
+// RenewID gives the session a new ID when it is saved, the old one is
+// invalidated. Call it when the privileges change, e.g. on login, so that
+// an ID planted in the browser by an attacker (session fixation) is useless.
+func (s *Session) RenewID() {
+	s.load().Renew = true
+}
+
+// IsNew reports whether the session was created by this request
+func (s *Session) IsNew() bool {
+	return s.load().IsNew
+}
+
+// Get returns the value of key, nil if there is none
+func (s *Session) Get(key string) interface{} {
+	return s.load().Values[key]
+}
+
+// Set sets the value of key
+func (s *Session) Set(key string, value interface{}) {
+	s.load().Values[key] = value
+}
+
+// Delete removes key from the session
+func (s *Session) Delete(key string) {
+	delete(s.load().Values, key)
+}
+
+// Clear removes all values from the session
+func (s *Session) Clear() {
+	data := s.load()
+	for key := range data.Values {
+		delete(data.Values, key)
+	}
+}
+
+// Flash adds a message that is read once with Flashes,
+// e.g. to show a notice after a redirect
+func (s *Session) Flash(value interface{}) {
+	data := s.load()
+	flashes, _ := data.Values[flashKey].([]interface{})
+	data.Values[flashKey] = append(flashes, value)
+}
+
+// Flashes returns the flash messages and removes them from the session
+func (s *Session) Flashes() []interface{} {
+	data := s.load()
+	flashes, _ := data.Values[flashKey].([]interface{})
+	delete(data.Values, flashKey)
+	return flashes
+}
+
+// Options sets the cookie options of the session,
+// a MaxAge < 0 deletes it when it is saved
+func (s *Session) Options(options Options) {
+	s.load().Options = options
+}
+
+// Save stores the session and sets its cookie. It must be called
+// before the response body is written, which sends the headers.
+func (s *Session) Save() error {
+	if s.c.Writer.Written() {
+		return ErrHeadersWritten
+	}
+	return s.store.Save(s.c.Writer, s.name, s.load())
+}
@@ -0,0 +1,271 @@
+package sessions
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gee"
+)
+
+var (
+	hashKey = bytes.Repeat([]byte("h"), 32)
+	encKey  = bytes.Repeat([]byte("e"), 32)
+)
+
+func newEngine(store Store) *gee.Engine {
+	r := gee.New()
+	r.Use(Sessions("session", store))
+	r.GET("/set", func(c *gee.Context) {
+		s := Default(c)
+		s.Set("user", c.Query("user"))
+		s.Flash("welcome")
+		if err := s.Save(); err != nil {
+			c.Fail(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.String(http.StatusOK, "ok")
+	})
+	r.GET("/get", func(c *gee.Context) {
+		s := Default(c)
+		flashes := s.Flashes()
+		s.Save()
+		c.String(http.StatusOK, "%v %v", s.Get("user"), flashes)
+	})
+	r.GET("/logout", func(c *gee.Context) {
+		s := Default(c)
+		s.Clear()
+		s.Options(Options{Path: "/", MaxAge: -1})
+		s.Save()
+	})
+	return r
+}
+
+// do serves path with the cookie, and returns the body and the new cookie
+func do(t *testing.T, r *gee.Engine, path string, cookie *http.Cookie) (string, *http.Cookie) {
+	t.Helper()
+	req := httptest.NewRequest("GET", path, nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("%s answered %d %q", path, w.Code, w.Body.String())
+	}
+	resp := w.Result()
+	if len(resp.Cookies()) > 0 {
+		return w.Body.String(), resp.Cookies()[0]
+	}
+	return w.Body.String(), cookie
+}
+
+func TestCookieStore(t *testing.T) {
+	r := newEngine(NewCookieStore(hashKey, encKey))
+	_, cookie := do(t, r, "/set?user=gee", nil)
+	if !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode || cookie.Path != "/" || cookie.Secure {
+		t.Fatalf("cookie attributes %+v", cookie)
+	}
+	raw, _ := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if bytes.Contains(raw, []byte("gee")) {
+		t.Fatal("the encrypted cookie contains the plain value")
+	}
+
+	body, cookie := do(t, r, "/get", cookie)
+	if body != "gee [welcome]" {
+		t.Fatalf("session read %q", body)
+	}
+	if body, _ = do(t, r, "/get", cookie); body != "gee []" {
+		t.Fatalf("flash read twice: %q", body)
+	}
+
+	tampered := *cookie
+	tampered.Value = cookie.Value[:len(cookie.Value)-2] + "AA"
+	if body, _ = do(t, r, "/get", &tampered); body != "<nil> []" {
+		t.Fatalf("tampered cookie accepted: %q", body)
+	}
+
+	_, deleted := do(t, r, "/logout", cookie)
+	if deleted.MaxAge >= 0 || deleted.Value != "" {
+		t.Fatalf("logout cookie %+v", deleted)
+	}
+}
+
+func TestCookieStoreKeyRotation(t *testing.T) {
+	old := NewCookieStore(hashKey)
+	_, cookie := do(t, newEngine(old), "/set?user=gee", nil)
+
+	newKey := bytes.Repeat([]byte("n"), 32)
+	rotated := newEngine(NewCookieStore(newKey, encKey, hashKey, nil))
+	body, cookie := do(t, rotated, "/get", cookie)
+	if body != "gee [welcome]" {
+		t.Fatalf("cookie of the old key read %q", body)
+	}
+	// the cookie is saved with the new key, which the old store rejects
+	if body, _ = do(t, newEngine(old), "/get", cookie); body != "<nil> []" {
+		t.Fatalf("cookie of the new key read by the old store: %q", body)
+	}
+}
+
+func TestCookieStoreExpiry(t *testing.T) {
+	now := time.Now()
+	store := NewCookieStore(hashKey)
+	store.now = func() time.Time { return now }
+	store.Options.MaxAge = 60
+	r := newEngine(store)
+
+	_, cookie := do(t, r, "/set?user=gee", nil)
+	now = now.Add(2 * time.Minute)
+	if body, _ := do(t, r, "/get", cookie); body != "<nil> []" {
+		t.Fatalf("expired cookie read %q", body)
+	}
+}
+
+func TestCookieTooLong(t *testing.T) {
+	store := NewCookieStore(hashKey)
+	data := &Data{Values: map[string]interface{}{"big": strings.Repeat("x", maxCookieLength)}, Options: store.Options}
+	if err := store.Save(httptest.NewRecorder(), "session", data); err != ErrCookieTooLong {
+		t.Fatalf("saved a large cookie: %v", err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryStore(hashKey)
+	store.now = func() time.Time { return now }
+	store.Options.MaxAge = 0
+	store.Options.Secure = true
+	store.TTL = time.Hour
+	r := newEngine(store)
+
+	_, cookie := do(t, r, "/set?user=gee", nil)
+	if !cookie.Secure || cookie.MaxAge != 0 {
+		t.Fatalf("cookie attributes %+v", cookie)
+	}
+	if body, _ := do(t, r, "/get", cookie); body != "gee [welcome]" {
+		t.Fatalf("session read %q", body)
+	}
+
+	// a second session keeps the sweep from running before it is due
+	now = now.Add(30 * time.Minute)
+	do(t, r, "/set?user=other", nil)
+	if store.Len() != 2 {
+		t.Fatalf("%d sessions stored", store.Len())
+	}
+	now = now.Add(2 * time.Hour)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(cookie)
+	if data, err := store.Get(req, "session"); err != ErrExpiredCookie || !data.IsNew {
+		t.Fatalf("expired session read %v %v", data.Values, err)
+	}
+	do(t, r, "/set?user=third", nil)
+	if store.Len() != 1 {
+		t.Fatalf("expired sessions were not evicted, %d stored", store.Len())
+	}
+
+	_, cookie = do(t, r, "/set?user=gee", nil)
+	do(t, r, "/logout", cookie)
+	if body, _ := do(t, r, "/get", cookie); body != "<nil> []" {
+		t.Fatalf("deleted session read %q", body)
+	}
+}
+
+func TestSaveAfterWrite(t *testing.T) {
+	r := gee.New()
+	r.Use(Sessions("session", NewCookieStore(hashKey)))
+	var err error
+	r.GET("/", func(c *gee.Context) {
+		c.String(http.StatusOK, "body")
+		err = Default(c).Save()
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if err != ErrHeadersWritten {
+		t.Fatalf("Save after the body returned %v", err)
+	}
+}
+
+func TestSaveBehindTimeout(t *testing.T) {
+	store := NewMemoryStore(hashKey)
+	r := newEngine(store)
+	r.Use(gee.Timeout(20*time.Millisecond, gee.TimeoutOptions{}))
+	saved := make(chan error, 1)
+	r.GET("/slow", func(c *gee.Context) {
+		<-c.Done()
+		time.Sleep(30 * time.Millisecond)
+		s := Default(c)
+		s.Set("user", s.Get("user").(string)+" again")
+		saved <- s.Save()
+	})
+
+	_, cookie := do(t, r, "/set?user=gee", nil)
+	req := httptest.NewRequest("GET", "/slow", nil)
+	req.AddCookie(cookie)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	// the session is bound to the context the handler runs on,
+	// not to the one the timeout answered with
+	if err := <-saved; err != nil {
+		t.Fatalf("Save behind Timeout returned %v", err)
+	}
+	if body, _ := do(t, r, "/get", cookie); body != "gee again [welcome]" {
+		t.Fatalf("session saved behind Timeout read %q", body)
+	}
+}
+
+func TestMemoryStoreRenewID(t *testing.T) {
+	store := NewMemoryStore(hashKey)
+	r := newEngine(store)
+	r.GET("/login", func(c *gee.Context) {
+		s := Default(c)
+		s.RenewID()
+		s.Set("user", "admin")
+		if err := s.Save(); err != nil {
+			c.Fail(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.String(http.StatusOK, "ok")
+	})
+
+	// the attacker gets a session and plants its cookie in the browser
+	_, planted := do(t, r, "/set?user=guest", nil)
+	_, renewed := do(t, r, "/login", planted)
+	if renewed.Value == planted.Value {
+		t.Fatal("login kept the session id")
+	}
+	if body, _ := do(t, r, "/get", renewed); body != "admin [welcome]" {
+		t.Fatalf("renewed session read %q", body)
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(planted)
+	if data, err := store.Get(req, "session"); err == nil || !data.IsNew {
+		t.Fatalf("the planted id still reads %v", data.Values)
+	}
+	if store.Len() != 1 {
+		t.Fatalf("%d sessions stored after the renewal", store.Len())
+	}
+}
+
+func TestMemoryStoreSaveDeleted(t *testing.T) {
+	store := NewMemoryStore(hashKey)
+	r := newEngine(store)
+	_, cookie := do(t, r, "/set?user=gee", nil)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(cookie)
+	loaded, err := store.Get(req, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// another request logs out while the first one still runs
+	do(t, r, "/logout", cookie)
+
+	if err := store.Save(httptest.NewRecorder(), "session", loaded); err != ErrSessionDeleted {
+		t.Fatalf("saving a deleted session returned %v", err)
+	}
+	if store.Len() != 0 {
+		t.Fatalf("the deleted session was brought back, %d stored", store.Len())
+	}
+}
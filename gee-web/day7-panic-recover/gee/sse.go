@@ -0,0 +1,95 @@
+package gee
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Event is a Server-Sent Event,
+// refer https://html.spec.whatwg.org/multipage/server-sent-events.html
+type Event struct {
+	ID    string      // sent back by the browser as Last-Event-ID on reconnect
+	Event string      // event type, "message" when empty
+	Retry uint        // reconnection time in milliseconds, omitted when 0
+	Data  interface{} // strings and []byte are sent as is, others as JSON
+}
+
+// fieldReplacer removes the characters that would end an id or event field
+var fieldReplacer = strings.NewReplacer("\n", "", "\r", "", "\x00", "")
+
+// dataReplacer splits data into lines, each sent as its own data field
+var dataReplacer = strings.NewReplacer("\r\n", "\ndata: ", "\r", "\ndata: ", "\n", "\ndata: ")
+
+// Stream calls step until it returns false or the client disconnects,
+// flushing after every step. It returns true if the client disconnected.
+func (c *Context) Stream(step func(w io.Writer) bool) bool {
+	done := c.Req.Context().Done()
+	for {
+		select {
+		case <-done:
+			return true
+		default:
+			keepOpen := step(c.Writer)
+			c.Writer.Flush()
+			if !keepOpen {
+				return false
+			}
+		}
+	}
+}
+
+// SSEvent writes and flushes a named Server-Sent Event
+func (c *Context) SSEvent(name string, data interface{}) error {
+	return c.WriteEvent(Event{Event: name, Data: data})
+}
+
+// WriteEvent writes and flushes a Server-Sent Event, the
+// text/event-stream headers are set before the first one
+func (c *Context) WriteEvent(e Event) error {
+	if !c.Writer.Written() {
+		header := c.Writer.Header()
+		header.Set("Content-Type", "text/event-stream; charset=utf-8")
+		header.Set("Cache-Control", "no-cache")
+		header.Set("X-Accel-Buffering", "no")
+	}
+
+	var data string
+	switch v := e.Data.(type) {
+	case string:
+		data = v
+	case []byte:
+		data = string(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		data = string(b)
+	}
+
+	var frame strings.Builder
+	if e.ID != "" {
+		frame.WriteString("id: " + fieldReplacer.Replace(e.ID) + "\n")
+	}
+	if e.Event != "" {
+		frame.WriteString("event: " + fieldReplacer.Replace(e.Event) + "\n")
+	}
+	if e.Retry > 0 {
+		frame.WriteString("retry: " + strconv.FormatUint(uint64(e.Retry), 10) + "\n")
+	}
+	frame.WriteString("data: " + dataReplacer.Replace(data) + "\n\n")
+
+	if _, err := io.WriteString(c.Writer, frame.String()); err != nil {
+		return err
+	}
+	c.Writer.Flush()
+	return nil
+}
+
+// LastEventID returns the id of the last event the client received
+// before it reconnected, to resume the stream from there
+func (c *Context) LastEventID() string {
+	return c.Req.Header.Get("Last-Event-ID")
+}
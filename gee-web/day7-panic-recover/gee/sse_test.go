@@ -0,0 +1,101 @@
+package gee
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWriteEvent(t *testing.T) {
+	r := New()
+	r.GET("/events", func(c *Context) {
+		c.WriteEvent(Event{ID: "1\n2", Event: "log", Retry: 3000, Data: "line1\nline2\r\nline3"})
+		c.SSEvent("json", H{"ok": true})
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/events", nil))
+	want := "id: 12\nevent: log\nretry: 3000\ndata: line1\ndata: line2\ndata: line3\n\n" +
+		"event: json\ndata: {\"ok\":true}\n\n"
+	if w.Body.String() != want {
+		t.Fatalf("unexpected frames %q", w.Body.String())
+	}
+	if w.Header().Get("Content-Type") != "text/event-stream; charset=utf-8" || !w.Flushed {
+		t.Fatal("events should be flushed as text/event-stream")
+	}
+}
+
+func TestStreamIncremental(t *testing.T) {
+	r := New()
+	next := make(chan struct{})
+	r.GET("/logs", func(c *Context) {
+		i, _ := strconv.Atoi(c.LastEventID())
+		c.Stream(func(w io.Writer) bool {
+			i++
+			c.WriteEvent(Event{ID: strconv.Itoa(i), Data: "step " + strconv.Itoa(i)})
+			if i == 3 {
+				return false
+			}
+			<-next
+			return true
+		})
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/logs", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	readEvent := func() string {
+		var lines []string
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			if line == "\n" {
+				return strings.Join(lines, "")
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	// the second step blocks on next, so the event must arrive before it
+	if e := readEvent(); e != "id: 2\ndata: step 2\n" {
+		t.Fatalf("unexpected event %q", e)
+	}
+	close(next)
+	if e := readEvent(); e != "id: 3\ndata: step 3\n" {
+		t.Fatalf("unexpected event %q", e)
+	}
+}
+
+func TestStreamClientGone(t *testing.T) {
+	r := New()
+	ctx, cancel := context.WithCancel(context.Background())
+	steps := 0
+	var gone bool
+	r.GET("/logs", func(c *Context) {
+		gone = c.Stream(func(w io.Writer) bool {
+			steps++
+			if steps == 2 {
+				cancel()
+			}
+			return true
+		})
+	})
+	req := httptest.NewRequest("GET", "/logs", nil).WithContext(ctx)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	if !gone || steps != 2 {
+		t.Fatalf("Stream should stop when the client disconnects, gone=%t steps=%d", gone, steps)
+	}
+}
@@ -0,0 +1,255 @@
+package gee
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StaticConfig configures how RouterGroup.StaticWithConfig serves files
+type StaticConfig struct {
+	// Index is served for directories, "index.html" if empty
+	Index string
+	// Browse lists the directories that have no index file
+	Browse bool
+	// Precompressed serves name.gz instead of name when it exists
+	// and the client accepts gzip
+	Precompressed bool
+	// SPA serves the root index for missing paths without a file
+	// extension, so a single page app can route on the client
+	SPA bool
+	// MaxAge sets Cache-Control to public with this max-age, if not 0
+	MaxAge time.Duration
+}
+
+// Static serves the files under the root directory
+func (group *RouterGroup) Static(relativePath string, root string) {
+	group.StaticFS(relativePath, http.Dir(root))
+}
+
+// StaticFS serves the files of fs, e.g. http.Dir or http.FS
+func (group *RouterGroup) StaticFS(relativePath string, fs http.FileSystem) {
+	handler := group.createStaticHandler(fs, StaticConfig{})
+	urlPattern := path.Join(relativePath, "/*filepath")
+	group.GET(urlPattern, handler)
+	group.HEAD(urlPattern, handler)
+}
+
+// StaticEmbed serves the files under dir of fsys, e.g. an embed.FS
+// whose paths start with the name of the embedded directory
+func (group *RouterGroup) StaticEmbed(relativePath string, fsys fs.FS, dir string) {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	group.StaticFS(relativePath, http.FS(sub))
+}
+
+// StaticWithConfig serves the files of fs with the config. Unlike
+// StaticFS, relativePath itself is registered as well, to serve the
+// index of the root, e.g. for a single page app mounted on "/".
+func (group *RouterGroup) StaticWithConfig(relativePath string, fs http.FileSystem, config StaticConfig) {
+	handler := group.createStaticHandler(fs, config)
+	urlPattern := path.Join(relativePath, "/*filepath")
+	group.GET(relativePath, handler)
+	group.HEAD(relativePath, handler)
+	group.GET(urlPattern, handler)
+	group.HEAD(urlPattern, handler)
+}
+
+// StaticFile serves a single file of the local file system
+func (group *RouterGroup) StaticFile(relativePath string, file string) {
+	group.StaticFileFS(relativePath, filepath.Base(file), http.Dir(filepath.Dir(file)))
+}
+
+// StaticFileFS serves the file name of fs
+func (group *RouterGroup) StaticFileFS(relativePath string, name string, fs http.FileSystem) {
+	tags := &etagCache{}
+	handler := func(c *Context) {
+		serveFile(c, fs, "/"+strings.TrimPrefix(name, "/"), StaticConfig{}, tags)
+	}
+	group.GET(relativePath, handler)
+	group.HEAD(relativePath, handler)
+}
+
+// create static handler
+func (group *RouterGroup) createStaticHandler(fs http.FileSystem, config StaticConfig) HandlerFunc {
+	if config.Index == "" {
+		config.Index = "index.html"
+	}
+	tags := &etagCache{}
+	return func(c *Context) {
+		serveFile(c, fs, path.Clean("/"+c.Param("filepath")), config, tags)
+	}
+}
+
+// serveFile serves the file name of fs. Conditional requests with
+// If-None-Match and If-Modified-Since, and Range requests are
+// answered by http.ServeContent.
+func serveFile(c *Context, fs http.FileSystem, name string, config StaticConfig, tags *etagCache) {
+	f, err := fs.Open(name)
+	if err != nil {
+		if config.SPA && os.IsNotExist(err) && path.Ext(name) == "" {
+			name = "/" + config.Index
+			f, err = fs.Open(name)
+		}
+		if err != nil {
+			staticError(c, err)
+			return
+		}
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		staticError(c, err)
+		return
+	}
+
+	if fi.IsDir() {
+		// relative links of directories only work with a trailing slash,
+		// the target is relative as the path may start with // which
+		// browsers would read as another host
+		if !strings.HasSuffix(c.Req.URL.Path, "/") {
+			target := "./" + path.Base(path.Clean(c.Req.URL.Path)) + "/"
+			if c.Req.URL.RawQuery != "" {
+				target += "?" + c.Req.URL.RawQuery
+			}
+			c.SetHeader("Location", target)
+			c.Status(http.StatusMovedPermanently)
+			return
+		}
+		index, err := fs.Open(path.Join(name, config.Index))
+		if err == nil {
+			defer index.Close()
+			if ifi, err := index.Stat(); err == nil && !ifi.IsDir() {
+				f, fi, name = index, ifi, path.Join(name, config.Index)
+			}
+		}
+		if fi.IsDir() {
+			if !config.Browse {
+				c.String(http.StatusForbidden, "403 FORBIDDEN\n")
+				return
+			}
+			listDir(c, f)
+			return
+		}
+	}
+
+	file := name
+	if config.Precompressed {
+		addVary(c.Writer.Header(), "Accept-Encoding")
+		if acceptsGzip(c.Req) {
+			if gz, err := fs.Open(name + ".gz"); err == nil {
+				defer gz.Close()
+				if gzfi, err := gz.Stat(); err == nil && !gzfi.IsDir() {
+					ctype := mime.TypeByExtension(path.Ext(name))
+					if ctype == "" {
+						ctype = "application/octet-stream"
+					}
+					c.SetHeader("Content-Type", ctype)
+					c.SetHeader("Content-Encoding", "gzip")
+					f, fi, file = gz, gzfi, name+".gz"
+				}
+			}
+		}
+	}
+
+	tag, err := tags.etag(file, f, fi)
+	if err != nil {
+		staticError(c, err)
+		return
+	}
+	c.SetHeader("ETag", tag)
+	if config.MaxAge > 0 {
+		c.SetHeader("Cache-Control", "public, max-age="+strconv.Itoa(int(config.MaxAge.Seconds())))
+	}
+	http.ServeContent(c.Writer, c.Req, fi.Name(), fi.ModTime(), f)
+}
+
+// etagCache keeps the tags of the files without a modification time,
+// hashing them on every request would be costly. Such files, e.g. those
+// of an embed.FS, are not expected to change.
+type etagCache struct {
+	tags sync.Map // etagKey to tag
+}
+
+type etagKey struct {
+	name string
+	size int64
+}
+
+// etag is a strong validator built from the size and modification time,
+// precompressed files get their own since they are another representation.
+// Files without a modification time are told apart by a hash of their content.
+func (cache *etagCache) etag(name string, f http.File, fi os.FileInfo) (string, error) {
+	if !fi.ModTime().IsZero() {
+		return fmt.Sprintf(`"%x-%x"`, fi.ModTime().UnixNano(), fi.Size()), nil
+	}
+	key := etagKey{name, fi.Size()}
+	if tag, ok := cache.tags.Load(key); ok {
+		return tag.(string), nil
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	tag := fmt.Sprintf(`"%x-%x"`, h.Sum(nil)[:16], fi.Size())
+	cache.tags.Store(key, tag)
+	return tag, nil
+}
+
+func acceptsGzip(req *http.Request) bool {
+	return acceptedEncoding(req, "gzip") != ""
+}
+
+func staticError(c *Context, err error) {
+	switch {
+	case os.IsNotExist(err):
+		c.String(http.StatusNotFound, "404 NOT FOUND: %s\n", c.Path)
+	case os.IsPermission(err):
+		c.String(http.StatusForbidden, "403 FORBIDDEN\n")
+	default:
+		c.String(http.StatusInternalServerError, "500 INTERNAL SERVER ERROR\n")
+	}
+}
+
+// listDir renders a plain html list of the directory entries
+func listDir(c *Context, dir http.File) {
+	entries, err := dir.Readdir(-1)
+	if err != nil {
+		staticError(c, err)
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	var b strings.Builder
+	b.WriteString("<!doctype html>\n<pre>\n")
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			name += "/"
+		}
+		href := (&url.URL{Path: name}).String()
+		fmt.Fprintf(&b, "<a href=\"%s\">%s</a>\n", html.EscapeString(href), html.EscapeString(name))
+	}
+	b.WriteString("</pre>\n")
+	c.SetHeader("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	c.Writer.Write([]byte(b.String()))
+}
@@ -0,0 +1,232 @@
+package gee
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var staticModTime = time.Date(2020, 1, 9, 0, 0, 0, 0, time.UTC)
+
+func newStaticFS() fstest.MapFS {
+	file := func(data string) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte(data), ModTime: staticModTime}
+	}
+	return fstest.MapFS{
+		"public/index.html":      file("<h1>gee</h1>"),
+		"public/app.js":          file("console.log('gee')"),
+		"public/app.js.gz":       file("gzipped"),
+		"public/docs/intro.txt":  file("0123456789"),
+		"public/empty/.gitkeep":  file(""),
+		"public/a <b>/c&d.txt":   file("escaped"),
+		"public/docs/index.html": file("docs"),
+	}
+}
+
+func serveStatic(r *Engine, path string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestStaticEmbed(t *testing.T) {
+	r := New()
+	r.StaticEmbed("/assets", newStaticFS(), "public")
+
+	w := serveStatic(r, "/assets/app.js", nil)
+	if w.Code != http.StatusOK || w.Body.String() != "console.log('gee')" {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" || strings.HasPrefix(etag, "W/") || w.Header().Get("Last-Modified") == "" {
+		t.Fatal("files should have a strong ETag and Last-Modified")
+	}
+
+	if w := serveStatic(r, "/assets/app.js", map[string]string{"If-None-Match": etag}); w.Code != http.StatusNotModified {
+		t.Fatalf("matching ETag should give 304, got %d", w.Code)
+	}
+	since := staticModTime.Format(http.TimeFormat)
+	if w := serveStatic(r, "/assets/app.js", map[string]string{"If-Modified-Since": since}); w.Code != http.StatusNotModified {
+		t.Fatalf("unmodified file should give 304, got %d", w.Code)
+	}
+
+	w = serveStatic(r, "/assets/docs/intro.txt", map[string]string{"Range": "bytes=2-5"})
+	if w.Code != http.StatusPartialContent || w.Body.String() != "2345" || w.Header().Get("Content-Range") != "bytes 2-5/10" {
+		t.Fatalf("range should give 206, got %d %q", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/assets/missing.js", http.StatusNotFound},
+		{"/assets/../public/app.js", http.StatusNotFound},
+		{"/assets/empty/", http.StatusForbidden},
+		{"/assets/docs", http.StatusMovedPermanently},
+	}
+	for _, tt := range tests {
+		if w := serveStatic(r, tt.path, nil); w.Code != tt.code {
+			t.Fatalf("%s: status should be %d, got %d", tt.path, tt.code, w.Code)
+		}
+	}
+	if w := serveStatic(r, "/assets/docs/", nil); w.Body.String() != "docs" {
+		t.Fatalf("directories should serve their index, got %q", w.Body.String())
+	}
+}
+
+func TestStaticDirRedirect(t *testing.T) {
+	r := New()
+	r.StaticEmbed("/", newStaticFS(), "public")
+	tests := []struct {
+		path, location string
+	}{
+		{"/docs", "./docs/"},
+		{"/docs?lang=en", "./docs/?lang=en"},
+		// the redirect must not lead to another host
+		{"//example.org/../docs", "./docs/"},
+	}
+	for _, tt := range tests {
+		w := serveStatic(r, tt.path, nil)
+		if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != tt.location {
+			t.Fatalf("%s redirected %d to %q", tt.path, w.Code, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestStaticETagWithoutModTime(t *testing.T) {
+	tags := make(map[string]bool)
+	for _, data := range []string{"var a = 1", "var a = 2"} {
+		r := New()
+		r.StaticEmbed("/", fstest.MapFS{"a.js": {Data: []byte(data)}}, ".")
+		w := serveStatic(r, "/a.js", nil)
+		tag := w.Header().Get("ETag")
+		if w.Code != http.StatusOK || w.Body.String() != data || strings.HasPrefix(tag, `"-`) {
+			t.Fatalf("a.js answered %d %q with ETag %s", w.Code, w.Body.String(), tag)
+		}
+		tags[tag] = true
+		if w := serveStatic(r, "/a.js", map[string]string{"If-None-Match": tag}); w.Code != http.StatusNotModified {
+			t.Fatalf("matching ETag answered %d", w.Code)
+		}
+	}
+	if len(tags) != 2 {
+		t.Fatal("files of the same size without a modification time share an ETag")
+	}
+
+	// the content is hashed once, not on every request
+	fs := &countingFS{FileSystem: http.FS(fstest.MapFS{"a.js": {Data: []byte("var a = 1")}})}
+	r := New()
+	r.StaticFS("/", fs)
+	tag := serveStatic(r, "/a.js", nil).Header().Get("ETag")
+	fs.read = 0
+	if w := serveStatic(r, "/a.js", map[string]string{"If-None-Match": tag}); w.Code != http.StatusNotModified || fs.read != 0 {
+		t.Fatalf("matching ETag answered %d after reading %d bytes", w.Code, fs.read)
+	}
+}
+
+// countingFS counts the bytes read from its files
+type countingFS struct {
+	http.FileSystem
+	read int
+}
+
+func (fs *countingFS) Open(name string) (http.File, error) {
+	f, err := fs.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return countingFile{f, fs}, nil
+}
+
+type countingFile struct {
+	http.File
+	fs *countingFS
+}
+
+func (f countingFile) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	f.fs.read += n
+	return n, err
+}
+
+func TestStaticWithConfig(t *testing.T) {
+	r := New()
+	r.GET("/api/ping", func(c *Context) { c.String(http.StatusOK, "pong") })
+	r.StaticWithConfig("/", http.FS(newStaticFS()), StaticConfig{
+		Browse:        true,
+		Precompressed: true,
+		SPA:           true,
+		MaxAge:        time.Hour,
+	})
+
+	w := serveStatic(r, "/public/app.js", map[string]string{"Accept-Encoding": "br, gzip"})
+	if w.Body.String() != "gzipped" || w.Header().Get("Content-Encoding") != "gzip" ||
+		!strings.HasPrefix(w.Header().Get("Content-Type"), "text/javascript") {
+		t.Fatalf("gz sibling should be served, got %q %v", w.Body.String(), w.Header())
+	}
+	if w.Header().Get("Vary") != "Accept-Encoding" || w.Header().Get("Cache-Control") != "public, max-age=3600" {
+		t.Fatalf("unexpected cache headers %v", w.Header())
+	}
+	w = serveStatic(r, "/public/app.js", map[string]string{"Accept-Encoding": "gzip;q=0"})
+	if w.Body.String() != "console.log('gee')" || w.Header().Get("Content-Encoding") != "" {
+		t.Fatal("gzip;q=0 should get the plain file")
+	}
+
+	if w := serveStatic(r, "/public/", nil); w.Body.String() != "<h1>gee</h1>" {
+		t.Fatal("directory index should be served")
+	}
+	if w := serveStatic(r, "/", nil); !strings.Contains(w.Body.String(), `<a href="public/">public/</a>`) {
+		t.Fatal("root should be listed")
+	}
+	w = serveStatic(r, "/public/empty/", nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), ".gitkeep") {
+		t.Fatalf("directories without index should be listed, got %d", w.Code)
+	}
+	w = serveStatic(r, "/public/a%20%3Cb%3E/", nil)
+	if !strings.Contains(w.Body.String(), `<a href="c&amp;d.txt">c&amp;d.txt</a>`) {
+		t.Fatalf("listing should be escaped, got %q", w.Body.String())
+	}
+
+	if w := serveStatic(r, "/api/ping", nil); w.Body.String() != "pong" {
+		t.Fatal("routes should take precedence over static files")
+	}
+	if w := serveStatic(r, "/missing.js", nil); w.Code != http.StatusNotFound {
+		t.Fatal("missing files with an extension should not fall back")
+	}
+}
+
+func TestStaticSPA(t *testing.T) {
+	r := New()
+	r.StaticWithConfig("/", http.FS(newStaticFS()), StaticConfig{Index: "public/index.html", SPA: true})
+	for _, path := range []string{"/", "/users/42"} {
+		if w := serveStatic(r, path, nil); w.Code != http.StatusOK || w.Body.String() != "<h1>gee</h1>" {
+			t.Fatalf("%s should fall back to the index, got %d %q", path, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestStaticFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "gee")
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "favicon.ico")
+	ioutil.WriteFile(file, []byte("icon"), 0600)
+
+	r := New()
+	r.StaticFile("/favicon.ico", file)
+	r.Static("/files", dir)
+	if w := serveStatic(r, "/favicon.ico", nil); w.Code != http.StatusOK || w.Body.String() != "icon" {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+	if w := serveStatic(r, "/files/favicon.ico", nil); w.Body.String() != "icon" {
+		t.Fatal("Static should serve the directory")
+	}
+}
@@ -0,0 +1,174 @@
+package gee
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TimeoutOptions configures the Timeout middleware
+type TimeoutOptions struct {
+	// Handler answers timed out requests, e.g. with a 504 for a gateway.
+	// It defaults to a plain text 503.
+	Handler HandlerFunc
+}
+
+// Timeout runs the rest of the chain with a deadline d on the context of
+// c.Req. The response is buffered, so a request that times out is answered
+// by the timeout handler alone. Handlers should return once c.Req's
+// context is done, their writes then fail with http.ErrHandlerTimeout.
+// Streaming and websockets don't work behind Timeout.
+func Timeout(d time.Duration, options TimeoutOptions) HandlerFunc {
+	if d <= 0 {
+		panic("gee: timeout must be positive")
+	}
+	if options.Handler == nil {
+		options.Handler = func(c *Context) {
+			c.String(http.StatusServiceUnavailable, "503 SERVICE UNAVAILABLE\n")
+		}
+	}
+	return func(c *Context) {
+		ctx, cancel := context.WithTimeout(c.Req.Context(), d)
+		defer cancel()
+
+		// the chain runs on a copy, so the timeout handler can answer on
+		// c while the handlers are still running. Values in the Keys may
+		// point to c, it is kept out of the pool until they return.
+		tw := &timeoutWriter{header: make(http.Header), status: http.StatusOK, size: noWritten}
+		tc := c.Copy()
+		tc.Writer = tw
+		tc.Req = c.Req.WithContext(ctx)
+		tc.handlers = c.handlers
+		tc.index = c.index
+
+		done := make(chan struct{})
+		panicked := make(chan interface{}, 1)
+		atomic.AddInt32(&c.refs, 1)
+		go func() {
+			defer c.release()
+			defer func() {
+				if p := recover(); p != nil {
+					panicked <- p
+				}
+			}()
+			tc.Next()
+			close(done)
+		}()
+
+		select {
+		case p := <-panicked:
+			// let the Recovery in front of Timeout handle it
+			c.Abort()
+			panic(p)
+		case <-done:
+			c.index = tc.index
+			c.mergeTimeout(tc, tw)
+		case <-ctx.Done():
+			tw.mu.Lock()
+			tw.timedOut = true
+			tw.mu.Unlock()
+			c.Abort()
+			options.Handler(c)
+		}
+	}
+}
+
+// mergeTimeout copies the state the chain left on tc to c
+func (c *Context) mergeTimeout(tc *Context, tw *timeoutWriter) {
+	c.Errors = append(c.Errors, tc.Errors...)
+	c.StatusCode = tc.StatusCode
+	tc.mu.RLock()
+	for k, v := range tc.Keys {
+		c.Set(k, v)
+	}
+	tc.mu.RUnlock()
+
+	header := c.Writer.Header()
+	for k, v := range tw.header {
+		header[k] = v
+	}
+	c.Writer.WriteHeader(tw.status)
+	if tw.Written() {
+		c.Writer.Write(tw.buf.Bytes())
+	}
+}
+
+// timeoutWriter buffers the response of a chain running behind Timeout,
+// writes fail once the request timed out
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	buf      bytes.Buffer
+	status   int
+	size     int
+	timedOut bool
+}
+
+var _ ResponseWriter = &timeoutWriter{}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	if code > 0 && w.size == noWritten {
+		w.status = code
+	}
+	w.mu.Unlock()
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	w.mu.Lock()
+	if w.size == noWritten {
+		w.size = 0
+	}
+	w.mu.Unlock()
+}
+
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if w.size == noWritten {
+		w.size = 0
+	}
+	n, err := w.buf.Write(data)
+	w.size += n
+	return n, err
+}
+
+func (w *timeoutWriter) Status() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status
+}
+
+func (w *timeoutWriter) Size() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.size
+}
+
+func (w *timeoutWriter) Written() bool {
+	return w.Size() != noWritten
+}
+
+// Flush does nothing, the response is sent once the chain returns
+func (w *timeoutWriter) Flush() {}
+
+func (w *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, errors.New("gee: connections can't be hijacked behind Timeout")
+}
+
+func (w *timeoutWriter) CloseNotify() <-chan bool {
+	return make(chan bool)
+}
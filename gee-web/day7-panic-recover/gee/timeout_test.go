@@ -0,0 +1,171 @@
+package gee
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	r := New()
+	r.Use(func(c *Context) {
+		c.Next()
+		if c.GetString("user") != "gee" || len(c.Errors) != 1 {
+			t.Error("the keys or errors set behind Timeout are lost")
+		}
+	})
+	r.Use(Timeout(time.Second, TimeoutOptions{}))
+	r.GET("/fast", func(c *Context) {
+		if _, ok := c.Req.Context().Deadline(); !ok {
+			t.Error("the request context has no deadline")
+		}
+		c.Set("user", "gee")
+		c.Error(errors.New("logged"))
+		c.Next()
+	}, func(c *Context) {
+		c.SetHeader("X-Handler", "fast")
+		c.String(http.StatusCreated, "done")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/fast", nil))
+	if w.Code != http.StatusCreated || w.Body.String() != "done" || w.Header().Get("X-Handler") != "fast" {
+		t.Fatalf("fast handler answered %d %v %q", w.Code, w.Header(), w.Body.String())
+	}
+}
+
+func TestTimeoutSlowHandler(t *testing.T) {
+	var (
+		wg       sync.WaitGroup
+		writeErr error
+	)
+	r := New()
+	r.Use(Timeout(20*time.Millisecond, TimeoutOptions{
+		Handler: func(c *Context) {
+			c.JSON(http.StatusGatewayTimeout, H{"message": "too slow"})
+		},
+	}))
+	r.GET("/slow", func(c *Context) {
+		defer wg.Done()
+		c.SetHeader("X-Handler", "slow")
+		c.Writer.Write([]byte("partial"))
+		<-c.Req.Context().Done()
+		time.Sleep(10 * time.Millisecond)
+		_, writeErr = c.Writer.Write([]byte("late"))
+	})
+
+	wg.Add(1)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/slow", nil))
+	if w.Code != http.StatusGatewayTimeout || w.Body.String() != `{"message":"too slow"}`+"\n" ||
+		w.Header().Get("X-Handler") != "" {
+		t.Fatalf("slow handler answered %d %v %q", w.Code, w.Header(), w.Body.String())
+	}
+	wg.Wait()
+	if !errors.Is(writeErr, http.ErrHandlerTimeout) {
+		t.Fatalf("write after the timeout returned %v", writeErr)
+	}
+	if w.Body.String() != `{"message":"too slow"}`+"\n" {
+		t.Fatalf("the handler wrote after the timeout: %q", w.Body.String())
+	}
+}
+
+func TestTimeoutPanic(t *testing.T) {
+	r := New()
+	r.Use(Recovery(), Timeout(time.Second, TimeoutOptions{}))
+	r.GET("/panic", func(c *Context) {
+		panic("boom")
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/panic", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("panic behind Timeout answered %d", w.Code)
+	}
+}
+
+// TestTimeoutConcurrent is meant for the race detector, pooled contexts
+// are reused while the handlers of timed out requests still run
+func TestTimeoutConcurrent(t *testing.T) {
+	r := New()
+	r.Use(Timeout(50*time.Millisecond, TimeoutOptions{}))
+	r.GET("/:id", func(c *Context) {
+		c.Set("id", c.Param("id"))
+		if c.Param("id") == "slow" {
+			<-c.Done()
+			time.Sleep(50 * time.Millisecond)
+		}
+		c.String(http.StatusOK, "%s %s", c.Param("id"), c.GetString("id"))
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := "fast"
+			if i%2 == 0 {
+				id = "slow"
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("GET", "/"+id, nil))
+			if id == "fast" && (w.Code != http.StatusOK || w.Body.String() != "fast fast") {
+				t.Errorf("fast request answered %d %q", w.Code, w.Body.String())
+			}
+			if id == "slow" && w.Code != http.StatusServiceUnavailable {
+				t.Errorf("slow request answered %d %q", w.Code, w.Body.String())
+			}
+		}(i)
+	}
+	wg.Wait()
+	time.Sleep(100 * time.Millisecond)
+}
+
+// TestTimeoutKeysHoldContext is meant for the race detector, a value in
+// the Keys holding the pooled context must stay valid in the handlers
+// of timed out requests
+func TestTimeoutKeysHoldContext(t *testing.T) {
+	var handlers sync.WaitGroup
+	r := New()
+	r.Use(func(c *Context) {
+		c.Set("outer", c)
+	})
+	r.Use(Timeout(20*time.Millisecond, TimeoutOptions{}))
+	r.GET("/:id", func(c *Context) {
+		defer handlers.Done()
+		if c.Param("id") == "slow" {
+			<-c.Done()
+			time.Sleep(50 * time.Millisecond)
+		}
+		outer := c.MustGet("outer").(*Context)
+		cookie, err := outer.Req.Cookie("id")
+		if err != nil || cookie.Value != c.Param("id") {
+			t.Errorf("the context in the keys belongs to another request: %v %v", cookie, err)
+		}
+	})
+	request := func(id string) {
+		req := httptest.NewRequest("GET", "/"+id, nil)
+		req.AddCookie(&http.Cookie{Name: "id", Value: id})
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// the slow requests time out, the fast ones reuse
+	// the contexts while the slow handlers still run
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		handlers.Add(1)
+		go func() {
+			defer wg.Done()
+			request("slow")
+		}()
+	}
+	wg.Wait()
+	for i := 0; i < 100; i++ {
+		handlers.Add(1)
+		request("fast")
+	}
+	handlers.Wait()
+}
@@ -5,48 +5,147 @@ import (
 	"strings"
 )
 
+// node is a node of the compressed radix tree. Static nodes hold a
+// shared prefix of the registered paths in part, wildcard nodes hold
+// the whole ":name" or "*name" segment.
 type node struct {
-	pattern  string
-	part     string
-	children []*node
-	isWild   bool
+	pattern    string        // registered route, empty if no route ends here
+	part       string        // static prefix or wildcard segment
+	indices    string        // first byte of every static child
+	children   []*node       // static children, in the order of indices
+	paramChild *node         // :param child, tried after static children
+	catchAll   *node         // *catchall child, tried last
+	isWild     bool          // part is a :param or *catchall
+	handlers   []HandlerFunc // middlewares and handler of the route
 }
 
 func (n *node) String() string {
 	return fmt.Sprintf("node{pattern=%s, part=%s, isWild=%t}", n.pattern, n.part, n.isWild)
 }
 
-func (n *node) insert(pattern string, parts []string, height int) {
-	if len(parts) == height {
-		n.pattern = pattern
-		return
+// insert adds the route described by the parsed parts of pattern,
+// and returns the node the route ends at
+func (n *node) insert(pattern string, parts []string) (*node, error) {
+	cur := n
+	static := ""
+	for _, part := range parts {
+		static += "/"
+		if part[0] != ':' && part[0] != '*' {
+			static += part
+			continue
+		}
+		cur = cur.insertStatic(static)
+		static = ""
+
+		child := &cur.paramChild
+		if part[0] == '*' {
+			child = &cur.catchAll
+		}
+		if *child == nil {
+			*child = &node{part: part, isWild: true}
+		} else if (*child).part != part {
+			return nil, fmt.Errorf("wildcard %s in route %s conflicts with %s in existing route %s",
+				part, pattern, (*child).part, (*child).anyPattern())
+		}
+		cur = *child
+	}
+	if len(parts) == 0 {
+		static = "/"
+	}
+	if static != "" {
+		cur = cur.insertStatic(static)
 	}
 
-	part := parts[height]
-	child := n.matchChild(part)
-	if child == nil {
-		child = &node{part: part, isWild: part[0] == ':' || part[0] == '*'}
-		n.children = append(n.children, child)
+	if cur.pattern != "" {
+		return nil, fmt.Errorf("route %s conflicts with existing route %s", pattern, cur.pattern)
 	}
-	child.insert(pattern, parts, height+1)
+	cur.pattern = pattern
+	return cur, nil
 }
 
-func (n *node) search(parts []string, height int) *node {
-	if len(parts) == height || strings.HasPrefix(n.part, "*") {
+// insertStatic walks down the static children along path, splitting
+// nodes where path diverges, and returns the node path ends at.
+// Nodes that routes end at are never replaced, only re-parented.
+func (n *node) insertStatic(path string) *node {
+	for {
+		i := strings.IndexByte(n.indices, path[0])
+		if i < 0 {
+			child := &node{part: path}
+			n.indices += path[:1]
+			n.children = append(n.children, child)
+			return child
+		}
+
+		child := n.children[i]
+		l := longestCommonPrefix(child.part, path)
+		if l < len(child.part) {
+			prefix := &node{
+				part:     child.part[:l],
+				indices:  child.part[l : l+1],
+				children: []*node{child},
+			}
+			child.part = child.part[l:]
+			n.children[i] = prefix
+			child = prefix
+		}
+		if l == len(path) {
+			return child
+		}
+		path = path[l:]
+		n = child
+	}
+}
+
+func longestCommonPrefix(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// search returns the route node matching path, appending the values
+// of wildcards to params. Static children are preferred over :param,
+// and :param over *catchall, backtracking when a branch fails.
+func (n *node) search(path string, params *Params) *node {
+	if path == "" {
 		if n.pattern == "" {
 			return nil
 		}
 		return n
 	}
 
-	part := parts[height]
-	children := n.matchChildren(part)
+	for i := 0; i < len(n.indices); i++ {
+		if n.indices[i] == path[0] {
+			child := n.children[i]
+			if strings.HasPrefix(path, child.part) {
+				if result := child.search(path[len(child.part):], params); result != nil {
+					return result
+				}
+			}
+			break
+		}
+	}
+
+	if child := n.paramChild; child != nil {
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		if end > 0 {
+			*params = append(*params, Param{Key: child.part[1:], Value: path[:end]})
+			if result := child.search(path[end:], params); result != nil {
+				return result
+			}
+			*params = (*params)[:len(*params)-1]
+		}
+	}
 
-	for _, child := range children {
-		result := child.search(parts, height+1)
-		if result != nil {
-			return result
+	if child := n.catchAll; child != nil {
+		if len(child.part) > 1 {
+			*params = append(*params, Param{Key: child.part[1:], Value: path})
 		}
+		return child
 	}
 
 	return nil
@@ -59,23 +158,20 @@ func (n *node) travel(list *([]*node)) {
 	for _, child := range n.children {
 		child.travel(list)
 	}
-}
-
-func (n *node) matchChild(part string) *node {
-	for _, child := range n.children {
-		if child.part == part || child.isWild {
-			return child
-		}
+	if n.paramChild != nil {
+		n.paramChild.travel(list)
+	}
+	if n.catchAll != nil {
+		n.catchAll.travel(list)
 	}
-	return nil
 }
 
-func (n *node) matchChildren(part string) []*node {
-	nodes := make([]*node, 0)
-	for _, child := range n.children {
-		if child.part == part || child.isWild {
-			nodes = append(nodes, child)
-		}
+// anyPattern returns a route registered at or below n, for error messages
+func (n *node) anyPattern() string {
+	var nodes []*node
+	n.travel(&nodes)
+	if len(nodes) == 0 {
+		return n.part
 	}
-	return nodes
+	return nodes[0].pattern
 }
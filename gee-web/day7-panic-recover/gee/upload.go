@@ -0,0 +1,136 @@
+package gee
+
+import (
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const defaultMultipartMemory = 32 << 20 // 32 MB
+
+// ErrBodyTooLarge is returned when reading a request body
+// larger than Engine.MaxRequestBodySize
+var ErrBodyTooLarge = errors.New("gee: request body too large")
+
+// maxBytesReader fails with ErrBodyTooLarge once more than n bytes are read
+type maxBytesReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *maxBytesReader) Read(p []byte) (int, error) {
+	if r.n < 0 {
+		return 0, ErrBodyTooLarge
+	}
+	if int64(len(p)) > r.n+1 {
+		p = p[:r.n+1]
+	}
+	n, err := r.ReadCloser.Read(p)
+	if int64(n) > r.n {
+		r.n = -1
+		return n - 1, ErrBodyTooLarge
+	}
+	r.n -= int64(n)
+	return n, err
+}
+
+// limitBody applies MaxRequestBodySize, requests that announce a larger
+// body are answered with 413 without running the route handlers
+func (engine *Engine) limitBody(c *Context) bool {
+	max := engine.MaxRequestBodySize
+	if max <= 0 || c.Req.Body == nil || c.Req.Body == http.NoBody {
+		return true
+	}
+	if c.Req.ContentLength > max {
+		c.handlers = engine.combineHandlers([]HandlerFunc{func(c *Context) {
+			c.String(http.StatusRequestEntityTooLarge, "413 REQUEST ENTITY TOO LARGE\n")
+		}})
+		c.Next()
+		return false
+	}
+	c.Req.Body = &maxBytesReader{ReadCloser: c.Req.Body, n: max}
+	return true
+}
+
+// MultipartForm parses the multipart form of the request with at most
+// Engine.MaxMultipartMemory in memory, the rest is stored on disk.
+// Filenames are sanitized, see SanitizeFilename. A body exceeding
+// Engine.MaxRequestBodySize aborts the chain with a 413 error.
+func (c *Context) MultipartForm() (*multipart.Form, error) {
+	if c.Req.MultipartForm == nil {
+		if err := c.Req.ParseMultipartForm(c.engine.MaxMultipartMemory); err != nil {
+			if errors.Is(err, ErrBodyTooLarge) {
+				c.AbortWithError(http.StatusRequestEntityTooLarge, err).SetType(ErrorTypePublic)
+			}
+			return nil, err
+		}
+		for _, fhs := range c.Req.MultipartForm.File {
+			for _, fh := range fhs {
+				fh.Filename = SanitizeFilename(fh.Filename)
+			}
+		}
+	}
+	return c.Req.MultipartForm, nil
+}
+
+// FormFile returns the first file of the multipart form field name
+func (c *Context) FormFile(name string) (*multipart.FileHeader, error) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, err
+	}
+	fhs := form.File[name]
+	if len(fhs) == 0 {
+		return nil, http.ErrMissingFile
+	}
+	return fhs[0], nil
+}
+
+// SaveUploadedFile writes the uploaded file to dst, creating the
+// directories of dst if needed. dst is used as is, build it from
+// a trusted directory and the sanitized fh.Filename.
+func (c *Context) SaveUploadedFile(fh *multipart.FileHeader, dst string) error {
+	src, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0750); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// SanitizeFilename reduces a client supplied filename to its base name,
+// so it cannot traverse out of the upload directory. Separators of both
+// unix and windows paths, control characters and leading dots are removed.
+func SanitizeFilename(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.Replace(name, "\\", "/", -1)
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.TrimLeft(strings.TrimSpace(name), ".")
+	if name == "" {
+		return "upload"
+	}
+	return name
+}
@@ -0,0 +1,113 @@
+package gee
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newUploadRequest(t *testing.T, files map[string]string) *http.Request {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	for name, content := range files {
+		part, err := mw.CreateFormFile("file", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write([]byte(content))
+	}
+	mw.WriteField("note", "hello")
+	mw.Close()
+	req := httptest.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestSaveUploadedFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "gee")
+	defer os.RemoveAll(dir)
+
+	r := New()
+	r.POST("/upload", func(c *Context) {
+		fh, err := c.FormFile("file")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.SaveUploadedFile(fh, filepath.Join(dir, "assets", fh.Filename)); err != nil {
+			t.Fatal(err)
+		}
+		form, _ := c.MultipartForm()
+		c.String(http.StatusOK, "%s %s", fh.Filename, form.Value["note"][0])
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, newUploadRequest(t, map[string]string{"../../etc/passwd": "content"}))
+	if w.Body.String() != "passwd hello" {
+		t.Fatalf("unexpected response %q", w.Body.String())
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dir, "assets", "passwd")); err != nil || string(b) != "content" {
+		t.Fatalf("file should be saved in the upload directory, got %q %v", b, err)
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := map[string]string{
+		"photo.png":                "photo.png",
+		"../../etc/passwd":         "passwd",
+		`C:\Users\gee\report.pdf`:  "report.pdf",
+		"..":                       "upload",
+		"/":                        "upload",
+		".htaccess":                "htaccess",
+		"evil\x00.txt":             "evil.txt",
+		"dir/..\\..\\secret.txt":   "secret.txt",
+		"  spaced name.txt  ":      "spaced name.txt",
+		"../..":                    "upload",
+		"unicode/文件.txt":           "文件.txt",
+		"trailing/":                "upload",
+		"\r\nheader-injection.txt": "header-injection.txt",
+	}
+	for name, want := range tests {
+		if got := SanitizeFilename(name); got != want {
+			t.Fatalf("SanitizeFilename(%q) should be %q, got %q", name, want, got)
+		}
+	}
+}
+
+func TestMaxRequestBodySize(t *testing.T) {
+	r := New()
+	r.MaxRequestBodySize = 64
+	var reached bool
+	r.POST("/upload", func(c *Context) {
+		reached = true
+		if _, err := c.FormFile("file"); err == nil {
+			t.Error("FormFile should fail on a body over the limit")
+		}
+	})
+
+	// the body announces its length
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, newUploadRequest(t, map[string]string{"a.txt": strings.Repeat("a", 100)}))
+	if w.Code != http.StatusRequestEntityTooLarge || reached {
+		t.Fatalf("status should be 413 before the handler runs, got %d", w.Code)
+	}
+
+	// a chunked body is only caught while reading it
+	req := newUploadRequest(t, map[string]string{"a.txt": strings.Repeat("a", 100)})
+	req.ContentLength = -1
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge || !reached {
+		t.Fatalf("status should be 413 once the body is read, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/upload", strings.NewReader("small")))
+	if w.Code == http.StatusRequestEntityTooLarge {
+		t.Fatal("small bodies should pass")
+	}
+}
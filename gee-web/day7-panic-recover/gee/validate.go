@@ -0,0 +1,175 @@
+package gee
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError describes a field that failed a rule of its `binding` tag
+type FieldError struct {
+	Field string      `json:"field"` // path of the field, e.g. User.Email
+	Rule  string      `json:"rule"`  // e.g. required, min
+	Param string      `json:"param,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+func (e FieldError) Error() string {
+	if e.Param != "" {
+		return fmt.Sprintf("field %s failed on the '%s=%s' rule", e.Field, e.Rule, e.Param)
+	}
+	return fmt.Sprintf("field %s failed on the '%s' rule", e.Field, e.Rule)
+}
+
+// ValidationErrors is returned by the bind methods when
+// one or more fields break the rules of their `binding` tag
+type ValidationErrors []FieldError
+
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// validate checks the `binding` tags of the struct obj points to,
+// nested structs are checked as well. Supported rules are
+// required, omitempty, min=n, max=n, email and oneof=a b c, where min and
+// max compare the length of strings, slices and maps and the value of
+// numbers. omitempty skips the remaining rules when the field is empty.
+func validate(obj interface{}) error {
+	v := reflect.ValueOf(obj)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	var errs ValidationErrors
+	if err := validateStruct(v, v.Type().Name(), &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateStruct(v reflect.Value, path string, errs *ValidationErrors) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		fv := v.Field(i)
+		name := field.Name
+		if path != "" {
+			name = path + "." + name
+		}
+		if tag := field.Tag.Get("binding"); tag != "" && tag != "-" {
+			for _, rule := range strings.Split(tag, ",") {
+				if rule == "omitempty" {
+					if fv.IsZero() {
+						break
+					}
+					continue
+				}
+				ok, err := checkRule(fv, rule)
+				if err != nil {
+					return fmt.Errorf("gee: field %s: %v", name, err)
+				}
+				if !ok {
+					fe := FieldError{Field: name, Rule: rule}
+					if idx := strings.IndexByte(rule, '='); idx >= 0 {
+						fe.Rule, fe.Param = rule[:idx], rule[idx+1:]
+					}
+					if fv.CanInterface() {
+						fe.Value = fv.Interface()
+					}
+					*errs = append(*errs, fe)
+				}
+			}
+		}
+		for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct && fv.Type() != timeType {
+			if err := validateStruct(fv, name, errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkRule(v reflect.Value, rule string) (bool, error) {
+	name, param := rule, ""
+	if idx := strings.IndexByte(rule, '='); idx >= 0 {
+		name, param = rule[:idx], rule[idx+1:]
+	}
+	if name == "required" {
+		return !v.IsZero(), nil
+	}
+	// other rules have nothing to check on a nil pointer
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return true, nil
+		}
+		v = v.Elem()
+	}
+	switch name {
+	case "min", "max":
+		limit, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return false, fmt.Errorf("invalid %s param %q", name, param)
+		}
+		n, ok := measure(v)
+		if !ok {
+			return false, fmt.Errorf("%s is not supported on %s", name, v.Type())
+		}
+		if name == "min" {
+			return n >= limit, nil
+		}
+		return n <= limit, nil
+	case "email":
+		if v.Kind() != reflect.String {
+			return false, fmt.Errorf("email is not supported on %s", v.Type())
+		}
+		addr, err := mail.ParseAddress(v.String())
+		return err == nil && addr.Address == v.String(), nil
+	case "oneof":
+		s := fmt.Sprint(v.Interface())
+		for _, option := range strings.Fields(param) {
+			if s == option {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, fmt.Errorf("unknown binding rule %q", name)
+}
+
+// measure returns the length of strings, slices and maps and the value of numbers
+func measure(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
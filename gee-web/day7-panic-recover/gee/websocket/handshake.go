@@ -0,0 +1,266 @@
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// keyGUID is appended to the key of the client to compute the accept value
+const keyGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Upgrader upgrades http requests to WebSocket connections on the server
+type Upgrader struct {
+	// MaxMessageSize is the read limit of the connections, 1 MB if 0
+	MaxMessageSize int64
+	// WriteFragmentSize splits larger messages into fragments, 0 never splits
+	WriteFragmentSize int
+	// Subprotocols are the supported subprotocols in order of preference
+	Subprotocols []string
+	// CheckOrigin returns true if the request Origin is acceptable,
+	// if nil only requests without Origin or from the same host pass
+	CheckOrigin func(r *http.Request) bool
+}
+
+// Upgrade completes the handshake and takes over the connection of the
+// request. On failure an error response has been written already.
+// responseHeader is added to the 101 response, e.g. for cookies.
+func (u *Upgrader) Upgrade(w http.ResponseWriter, r *http.Request, responseHeader http.Header) (*Conn, error) {
+	if r.Method != http.MethodGet {
+		return u.fail(w, http.StatusMethodNotAllowed, "request method is not GET")
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		return u.fail(w, http.StatusBadRequest, "not a websocket handshake")
+	}
+	if r.Header.Get("Sec-Websocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return u.fail(w, http.StatusUpgradeRequired, "unsupported version")
+	}
+	checkOrigin := u.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		return u.fail(w, http.StatusForbidden, "origin not allowed")
+	}
+	key := r.Header.Get("Sec-Websocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return u.fail(w, http.StatusBadRequest, "invalid Sec-WebSocket-Key")
+	}
+	h, ok := w.(http.Hijacker)
+	if !ok {
+		return u.fail(w, http.StatusInternalServerError, "response does not implement http.Hijacker")
+	}
+
+	subprotocol := ""
+	offered := headerTokens(r.Header, "Sec-Websocket-Protocol")
+	// the first supported one the client offers, in the server's preference
+	for _, supported := range u.Subprotocols {
+		for _, o := range offered {
+			if subprotocol == "" && o == supported {
+				subprotocol = supported
+			}
+		}
+	}
+
+	netConn, brw, err := h.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	if brw.Reader.Buffered() > 0 {
+		netConn.Close()
+		return nil, errors.New("websocket: client sent data before the handshake completed")
+	}
+
+	header := http.Header{}
+	for k, vs := range responseHeader {
+		header[k] = vs
+	}
+	header.Set("Upgrade", "websocket")
+	header.Set("Connection", "Upgrade")
+	header.Set("Sec-WebSocket-Accept", acceptKey(key))
+	if subprotocol != "" {
+		header.Set("Sec-WebSocket-Protocol", subprotocol)
+	}
+	brw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	header.Write(brw)
+	brw.WriteString("\r\n")
+	if err := brw.Flush(); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	conn := newConn(netConn, brw.Reader, false, u.MaxMessageSize, u.WriteFragmentSize)
+	conn.subprotocol = subprotocol
+	return conn, nil
+}
+
+func (u *Upgrader) fail(w http.ResponseWriter, code int, reason string) (*Conn, error) {
+	http.Error(w, http.StatusText(code), code)
+	return nil, errors.New("websocket: " + reason)
+}
+
+// sameOrigin accepts requests without Origin, or whose Origin host is the Host
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// Dialer opens WebSocket connections as a client
+type Dialer struct {
+	// TLSClientConfig is used for wss:// urls
+	TLSClientConfig *tls.Config
+	// HandshakeTimeout bounds the dial and the handshake, 0 means no timeout
+	HandshakeTimeout time.Duration
+	// MaxMessageSize is the read limit of the connections, 1 MB if 0
+	MaxMessageSize int64
+	// WriteFragmentSize splits larger messages into fragments, 0 never splits
+	WriteFragmentSize int
+	// Subprotocols are offered to the server in order of preference
+	Subprotocols []string
+}
+
+// DefaultDialer is the Dialer used by Dial
+var DefaultDialer = &Dialer{HandshakeTimeout: 45 * time.Second}
+
+// Dial opens a connection to the ws:// or wss:// url with the DefaultDialer
+func Dial(urlStr string, requestHeader http.Header) (*Conn, *http.Response, error) {
+	return DefaultDialer.Dial(urlStr, requestHeader)
+}
+
+// Dial opens a connection to the ws:// or wss:// url. The response of the
+// server is returned for failed handshakes as well, to inspect the status.
+func (d *Dialer) Dial(urlStr string, requestHeader http.Header) (*Conn, *http.Response, error) {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, nil, err
+	}
+	host := u.Host
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "80")
+		}
+	case "wss":
+		u.Scheme = "https"
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		}
+	default:
+		return nil, nil, errors.New("websocket: bad scheme " + u.Scheme)
+	}
+
+	var deadline time.Time
+	if d.HandshakeTimeout > 0 {
+		deadline = time.Now().Add(d.HandshakeTimeout)
+	}
+	netConn, err := (&net.Dialer{Deadline: deadline}).Dial("tcp", host)
+	if err != nil {
+		return nil, nil, err
+	}
+	if u.Scheme == "https" {
+		cfg := &tls.Config{}
+		if d.TLSClientConfig != nil {
+			cfg = d.TLSClientConfig.Clone()
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName = u.Hostname()
+		}
+		netConn = tls.Client(netConn, cfg)
+	}
+	netConn.SetDeadline(deadline)
+
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		netConn.Close()
+		return nil, nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Host:       u.Host,
+	}
+	for k, vs := range requestHeader {
+		req.Header[k] = vs
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if len(d.Subprotocols) > 0 {
+		req.Header.Set("Sec-WebSocket-Protocol", strings.Join(d.Subprotocols, ", "))
+	}
+	if err := req.Write(netConn); err != nil {
+		netConn.Close()
+		return nil, nil, err
+	}
+
+	br := bufio.NewReader(netConn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		netConn.Close()
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols ||
+		!headerContains(resp.Header, "Upgrade", "websocket") ||
+		!headerContains(resp.Header, "Connection", "upgrade") ||
+		resp.Header.Get("Sec-Websocket-Accept") != acceptKey(key) {
+		netConn.Close()
+		return nil, resp, errors.New("websocket: bad handshake")
+	}
+	netConn.SetDeadline(time.Time{})
+
+	conn := newConn(netConn, br, true, d.MaxMessageSize, d.WriteFragmentSize)
+	conn.subprotocol = resp.Header.Get("Sec-Websocket-Protocol")
+	return conn, resp, nil
+}
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + keyGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// headerTokens returns the comma separated tokens of all values of the header
+func headerTokens(header http.Header, name string) []string {
+	var tokens []string
+	for _, v := range header[http.CanonicalHeaderKey(name)] {
+		for _, token := range strings.Split(v, ",") {
+			if token = strings.TrimSpace(token); token != "" {
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	return tokens
+}
+
+// headerContains reports whether the header has the token, ignoring case
+func headerContains(header http.Header, name, token string) bool {
+	for _, t := range headerTokens(header, name) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
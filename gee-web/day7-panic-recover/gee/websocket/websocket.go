@@ -0,0 +1,411 @@
+// Package websocket implements the WebSocket protocol of RFC 6455,
+// for servers upgraded from a http request and for clients.
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Message types, the values of the frame opcodes
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+
+	continuationFrame = 0
+)
+
+// Close codes, refer https://tools.ietf.org/html/rfc6455#section-7.4
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseMandatoryExtension      = 1010
+	CloseInternalServerErr       = 1011
+)
+
+const (
+	defaultMaxMessageSize = 1 << 20 // 1 MB
+	maxControlPayload     = 125
+)
+
+// ErrCloseSent is returned when writing after a close frame was sent
+var ErrCloseSent = errors.New("websocket: close sent")
+
+// CloseError is returned by ReadMessage when the peer closed the connection,
+// or when the connection was closed because the peer broke the protocol
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Text)
+}
+
+// IsCloseError reports whether err is a *CloseError with one of the codes
+func IsCloseError(err error, codes ...int) bool {
+	var e *CloseError
+	if !errors.As(err, &e) {
+		return false
+	}
+	for _, code := range codes {
+		if e.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Conn is a WebSocket connection. ReadMessage must be called from a single
+// goroutine, the write methods are safe to call concurrently.
+type Conn struct {
+	conn         net.Conn
+	br           *bufio.Reader
+	isClient     bool // clients mask their frames, servers don't
+	subprotocol  string
+	readLimit    int64
+	fragmentSize int
+
+	readErr     error
+	pongHandler func(appData string) error
+
+	wmu       sync.Mutex // serializes frames, a message is written at once
+	closeSent bool
+}
+
+func newConn(conn net.Conn, br *bufio.Reader, isClient bool, readLimit int64, fragmentSize int) *Conn {
+	if br == nil {
+		br = bufio.NewReader(conn)
+	}
+	if readLimit <= 0 {
+		readLimit = defaultMaxMessageSize
+	}
+	return &Conn{
+		conn:         conn,
+		br:           br,
+		isClient:     isClient,
+		readLimit:    readLimit,
+		fragmentSize: fragmentSize,
+	}
+}
+
+// Subprotocol returns the negotiated subprotocol
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// LocalAddr returns the local network address
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// RemoteAddr returns the remote network address
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// SetReadLimit sets the maximum size of a message read from the peer,
+// larger messages close the connection with CloseMessageTooBig
+func (c *Conn) SetReadLimit(limit int64) {
+	c.readLimit = limit
+}
+
+// SetReadDeadline sets the deadline for reads on the underlying connection
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the deadline for writes on the underlying connection
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// SetPongHandler sets the handler called by ReadMessage for pong frames
+func (c *Conn) SetPongHandler(h func(appData string) error) {
+	c.pongHandler = h
+}
+
+// WriteMessage writes a message. Data messages larger than the fragment
+// size of the connection are split into fragments, control messages
+// (close, ping, pong) are limited to 125 bytes.
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	switch messageType {
+	case TextMessage, BinaryMessage:
+	case CloseMessage, PingMessage, PongMessage:
+		if len(data) > maxControlPayload {
+			return errors.New("websocket: control message exceeds 125 bytes")
+		}
+	default:
+		return fmt.Errorf("websocket: unknown message type %d", messageType)
+	}
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closeSent {
+		return ErrCloseSent
+	}
+	if messageType == CloseMessage {
+		c.closeSent = true
+	}
+	if messageType >= CloseMessage || c.fragmentSize <= 0 || len(data) <= c.fragmentSize {
+		return c.writeFrame(true, byte(messageType), data)
+	}
+
+	opcode := byte(messageType)
+	for len(data) > c.fragmentSize {
+		if err := c.writeFrame(false, opcode, data[:c.fragmentSize]); err != nil {
+			return err
+		}
+		data = data[c.fragmentSize:]
+		opcode = continuationFrame
+	}
+	return c.writeFrame(true, opcode, data)
+}
+
+// WriteClose sends a close frame with the code and reason,
+// the peer is expected to answer with a close frame as well
+func (c *Conn) WriteClose(code int, text string) error {
+	return c.WriteMessage(CloseMessage, formatClose(code, text))
+}
+
+// Close sends a normal close frame if none was sent, and closes the
+// underlying connection without waiting for the peer's close frame
+func (c *Conn) Close() error {
+	c.WriteClose(CloseNormalClosure, "")
+	return c.conn.Close()
+}
+
+func (c *Conn) writeFrame(fin bool, opcode byte, payload []byte) error {
+	header := make([]byte, 2, 14)
+	header[0] = opcode
+	if fin {
+		header[0] |= 0x80
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		header[1] = byte(n)
+	case n <= 0xffff:
+		header[1] = 126
+		header = append(header, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(n))
+	default:
+		header[1] = 127
+		header = append(header, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(n))
+	}
+
+	var key [4]byte
+	if c.isClient {
+		if _, err := rand.Read(key[:]); err != nil {
+			return err
+		}
+		header[1] |= 0x80
+		header = append(header, key[:]...)
+	}
+	frame := append(header, payload...)
+	if c.isClient {
+		maskBytes(key, frame[len(header):])
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+func maskBytes(key [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= key[i&3]
+	}
+}
+
+type frame struct {
+	fin     bool
+	opcode  byte
+	payload []byte
+}
+
+// readFrame reads a single frame, remaining is the number of bytes
+// the current message may still grow by
+func (c *Conn) readFrame(remaining int64) (*frame, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		return nil, err
+	}
+	f := &frame{fin: head[0]&0x80 != 0, opcode: head[0] & 0x0f}
+	if head[0]&0x70 != 0 {
+		return nil, c.fail(CloseProtocolError, "reserved bits set without extension")
+	}
+	masked := head[1]&0x80 != 0
+	if masked == c.isClient {
+		return nil, c.fail(CloseProtocolError, "bad masking of frame")
+	}
+
+	length := int64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var b [2]byte
+		if _, err := io.ReadFull(c.br, b[:]); err != nil {
+			return nil, err
+		}
+		length = int64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err := io.ReadFull(c.br, b[:]); err != nil {
+			return nil, err
+		}
+		if b[0]&0x80 != 0 {
+			return nil, c.fail(CloseProtocolError, "invalid payload length")
+		}
+		length = int64(binary.BigEndian.Uint64(b[:]))
+	}
+
+	if f.opcode >= CloseMessage {
+		if !f.fin || length > maxControlPayload {
+			return nil, c.fail(CloseProtocolError, "invalid control frame")
+		}
+	} else if length > remaining {
+		return nil, c.fail(CloseMessageTooBig, "message too big")
+	}
+
+	var key [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.br, key[:]); err != nil {
+			return nil, err
+		}
+	}
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, f.payload); err != nil {
+		return nil, err
+	}
+	if masked {
+		maskBytes(key, f.payload)
+	}
+	return f, nil
+}
+
+// ReadMessage reads the next data message, reassembling fragments.
+// Pings are answered with pongs and a close frame from the peer is
+// echoed, after which a *CloseError is returned.
+func (c *Conn) ReadMessage() (messageType int, p []byte, err error) {
+	if c.readErr != nil {
+		return 0, nil, c.readErr
+	}
+	for {
+		f, err := c.readFrame(c.readLimit - int64(len(p)))
+		if err != nil {
+			if c.readErr == nil {
+				c.readErr = err
+			}
+			return 0, nil, err
+		}
+
+		switch f.opcode {
+		case PingMessage:
+			if err := c.WriteMessage(PongMessage, f.payload); err != nil && err != ErrCloseSent {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			if c.pongHandler != nil {
+				if err := c.pongHandler(string(f.payload)); err != nil {
+					return 0, nil, err
+				}
+			}
+			continue
+		case CloseMessage:
+			return 0, nil, c.handleClose(f.payload)
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, c.fail(CloseProtocolError, "expected continuation frame")
+			}
+			messageType = int(f.opcode)
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, c.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+		default:
+			return 0, nil, c.fail(CloseProtocolError, fmt.Sprintf("unknown opcode %d", f.opcode))
+		}
+
+		p = append(p, f.payload...)
+		if f.fin {
+			if messageType == TextMessage && !utf8.Valid(p) {
+				return 0, nil, c.fail(CloseInvalidFramePayloadData, "invalid UTF-8 in text message")
+			}
+			return messageType, p, nil
+		}
+	}
+}
+
+// handleClose echoes the close frame of the peer and returns it as error
+func (c *Conn) handleClose(payload []byte) error {
+	e := &CloseError{Code: CloseNoStatusReceived}
+	switch {
+	case len(payload) == 1:
+		return c.fail(CloseProtocolError, "invalid close frame")
+	case len(payload) >= 2:
+		e.Code = int(binary.BigEndian.Uint16(payload))
+		e.Text = string(payload[2:])
+		if !validCloseCode(e.Code) {
+			return c.fail(CloseProtocolError, "invalid close code")
+		}
+		if !utf8.ValidString(e.Text) {
+			return c.fail(CloseInvalidFramePayloadData, "invalid UTF-8 in close reason")
+		}
+	}
+	echo := []byte{}
+	if e.Code != CloseNoStatusReceived {
+		echo = formatClose(e.Code, "")
+	}
+	c.WriteMessage(CloseMessage, echo)
+	c.readErr = e
+	return e
+}
+
+// fail closes the connection because the peer broke the protocol
+func (c *Conn) fail(code int, text string) error {
+	c.WriteClose(code, text)
+	c.conn.Close()
+	c.readErr = &CloseError{Code: code, Text: text}
+	return c.readErr
+}
+
+func formatClose(code int, text string) []byte {
+	if code == CloseNoStatusReceived {
+		return []byte{}
+	}
+	if len(text) > maxControlPayload-2 {
+		text = text[:maxControlPayload-2]
+	}
+	b := make([]byte, 2+len(text))
+	binary.BigEndian.PutUint16(b, uint16(code))
+	copy(b[2:], text)
+	return b
+}
+
+// validCloseCode reports whether code may be sent in a close frame
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1011:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
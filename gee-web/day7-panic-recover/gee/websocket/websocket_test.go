@@ -0,0 +1,260 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newServer starts a server that upgrades every request with u and hands
+// the connection to handler, it returns the ws:// url of the server
+func newServer(t *testing.T, u *Upgrader, handler func(*Conn)) (string, func()) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := u.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handler(conn)
+	}))
+	return "ws" + strings.TrimPrefix(srv.URL, "http"), srv.Close
+}
+
+// echo writes every message back until the connection is closed
+func echo(conn *Conn) {
+	for {
+		messageType, p, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if err := conn.WriteMessage(messageType, p); err != nil {
+			return
+		}
+	}
+}
+
+func TestSubprotocolPreference(t *testing.T) {
+	url, stop := newServer(t, &Upgrader{Subprotocols: []string{"chat", "v2"}}, echo)
+	defer stop()
+
+	tests := []struct {
+		offered []string
+		want    string
+	}{
+		{[]string{"v2", "chat"}, "chat"}, // the server's preference wins
+		{[]string{"chat", "v2"}, "chat"},
+		{[]string{"v1", "v2"}, "v2"},
+		{[]string{"v1"}, ""},
+	}
+	for _, tt := range tests {
+		dialer := &Dialer{Subprotocols: tt.offered}
+		conn, _, err := dialer.Dial(url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if conn.Subprotocol() != tt.want {
+			t.Fatalf("offered %v, got subprotocol %q, want %q", tt.offered, conn.Subprotocol(), tt.want)
+		}
+		conn.Close()
+	}
+}
+
+func TestEcho(t *testing.T) {
+	url, stop := newServer(t, &Upgrader{}, echo)
+	defer stop()
+
+	conn, _, err := DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	long := strings.Repeat("geektutu", 20000) // needs a 64-bit length
+	messages := []struct {
+		messageType int
+		data        string
+	}{
+		{TextMessage, "hello"},
+		{BinaryMessage, "\x00\x01\x02"},
+		{TextMessage, strings.Repeat("a", 300)},
+		{TextMessage, long},
+	}
+	for _, m := range messages {
+		if err := conn.WriteMessage(m.messageType, []byte(m.data)); err != nil {
+			t.Fatal(err)
+		}
+		messageType, p, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if messageType != m.messageType || string(p) != m.data {
+			t.Fatalf("echo should return the message, got type %d len %d", messageType, len(p))
+		}
+	}
+}
+
+func TestFragmentation(t *testing.T) {
+	url, stop := newServer(t, &Upgrader{WriteFragmentSize: 3}, echo)
+	defer stop()
+
+	conn, _, err := (&Dialer{WriteFragmentSize: 4}).Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// a ping between the fragments must be answered, not break the message
+	conn.wmu.Lock()
+	conn.writeFrame(false, TextMessage, []byte("hel"))
+	conn.writeFrame(true, PingMessage, []byte("p"))
+	conn.writeFrame(true, continuationFrame, []byte("lo, 世界"))
+	conn.wmu.Unlock()
+
+	var pong string
+	conn.SetPongHandler(func(appData string) error {
+		pong = appData
+		return nil
+	})
+	messageType, p, err := conn.ReadMessage()
+	if err != nil || messageType != TextMessage || string(p) != "hello, 世界" {
+		t.Fatalf("fragments should be reassembled, got %q %v", p, err)
+	}
+	if pong != "p" {
+		t.Fatalf("ping should be answered with a pong, got %q", pong)
+	}
+
+	conn.WriteMessage(BinaryMessage, []byte("0123456789"))
+	if _, p, _ := conn.ReadMessage(); string(p) != "0123456789" {
+		t.Fatalf("fragmented write should be reassembled, got %q", p)
+	}
+}
+
+func TestCloseHandshake(t *testing.T) {
+	serverErr := make(chan error, 1)
+	url, stop := newServer(t, &Upgrader{}, func(conn *Conn) {
+		_, _, err := conn.ReadMessage()
+		serverErr <- err
+	})
+	defer stop()
+
+	conn, _, err := Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.WriteClose(4000, "bye")
+	if err := <-serverErr; !IsCloseError(err, 4000) || err.(*CloseError).Text != "bye" {
+		t.Fatalf("server should receive close 4000, got %v", err)
+	}
+	if _, _, err := conn.ReadMessage(); !IsCloseError(err, 4000) {
+		t.Fatalf("server should echo the close code, got %v", err)
+	}
+	if err := conn.WriteMessage(TextMessage, []byte("late")); err != ErrCloseSent {
+		t.Fatalf("writes after close should fail, got %v", err)
+	}
+}
+
+func TestProtocolErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame func(conn *Conn)
+		code  int
+	}{
+		{"too big", func(conn *Conn) {
+			conn.WriteMessage(BinaryMessage, make([]byte, 17))
+		}, CloseMessageTooBig},
+		{"too big in fragments", func(conn *Conn) {
+			conn.writeFrame(false, BinaryMessage, make([]byte, 10))
+			conn.writeFrame(true, continuationFrame, make([]byte, 10))
+		}, CloseMessageTooBig},
+		{"unmasked", func(conn *Conn) {
+			conn.conn.Write([]byte{0x81, 0x01, 'a'})
+		}, CloseProtocolError},
+		{"reserved bits", func(conn *Conn) {
+			conn.writeFrame(true, TextMessage|0x40, []byte("a"))
+		}, CloseProtocolError},
+		{"unexpected continuation", func(conn *Conn) {
+			conn.writeFrame(true, continuationFrame, []byte("a"))
+		}, CloseProtocolError},
+		{"interleaved message", func(conn *Conn) {
+			conn.writeFrame(false, TextMessage, []byte("a"))
+			conn.writeFrame(true, TextMessage, []byte("b"))
+		}, CloseProtocolError},
+		{"fragmented ping", func(conn *Conn) {
+			conn.writeFrame(false, PingMessage, []byte("a"))
+		}, CloseProtocolError},
+		{"invalid utf-8", func(conn *Conn) {
+			conn.writeFrame(true, TextMessage, []byte{0xff, 0xfe})
+		}, CloseInvalidFramePayloadData},
+		{"invalid close code", func(conn *Conn) {
+			conn.writeFrame(true, CloseMessage, formatClose(1005+1, ""))
+		}, CloseProtocolError},
+	}
+	for _, tt := range tests {
+		serverErr := make(chan error, 1)
+		url, stop := newServer(t, &Upgrader{MaxMessageSize: 16}, func(conn *Conn) {
+			_, _, err := conn.ReadMessage()
+			serverErr <- err
+		})
+		conn, _, err := Dial(url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tt.frame(conn)
+		if err := <-serverErr; !IsCloseError(err, tt.code) {
+			t.Fatalf("%s: server should fail with %d, got %v", tt.name, tt.code, err)
+		}
+		if _, _, err := conn.ReadMessage(); !IsCloseError(err, tt.code) {
+			t.Fatalf("%s: client should receive close %d, got %v", tt.name, tt.code, err)
+		}
+		conn.Close()
+		stop()
+	}
+}
+
+func TestHandshakeErrors(t *testing.T) {
+	u := &Upgrader{CheckOrigin: func(r *http.Request) bool {
+		return r.Header.Get("Origin") != "http://evil.com"
+	}}
+	handshake := func(modify func(r *http.Request)) int {
+		r := httptest.NewRequest("GET", "/ws", nil)
+		r.Header.Set("Connection", "keep-alive, Upgrade")
+		r.Header.Set("Upgrade", "websocket")
+		r.Header.Set("Sec-WebSocket-Version", "13")
+		r.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		modify(r)
+		w := httptest.NewRecorder()
+		if _, err := u.Upgrade(w, r, nil); err == nil {
+			t.Fatal("handshake should fail")
+		}
+		return w.Code
+	}
+
+	tests := []struct {
+		name   string
+		modify func(r *http.Request)
+		code   int
+	}{
+		{"method", func(r *http.Request) { r.Method = "POST" }, http.StatusMethodNotAllowed},
+		{"upgrade", func(r *http.Request) { r.Header.Del("Upgrade") }, http.StatusBadRequest},
+		{"version", func(r *http.Request) { r.Header.Set("Sec-WebSocket-Version", "8") }, http.StatusUpgradeRequired},
+		{"origin", func(r *http.Request) { r.Header.Set("Origin", "http://evil.com") }, http.StatusForbidden},
+		{"key", func(r *http.Request) { r.Header.Set("Sec-WebSocket-Key", "short") }, http.StatusBadRequest},
+		// httptest.ResponseRecorder cannot be hijacked
+		{"hijack", func(r *http.Request) {}, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if code := handshake(tt.modify); code != tt.code {
+			t.Fatalf("%s: status should be %d, got %d", tt.name, tt.code, code)
+		}
+	}
+
+	if acceptKey("dGhlIHNhbXBsZSBub25jZQ==") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatal("accept key should match the example of RFC 6455")
+	}
+	r := httptest.NewRequest("GET", "http://example.com/ws", nil)
+	r.Header.Set("Origin", "http://other.com")
+	if sameOrigin(r) {
+		t.Fatal("cross origin requests should be rejected by default")
+	}
+}
@@ -0,0 +1,33 @@
+package gee
+
+import (
+	"gee/websocket"
+)
+
+// Conn is a websocket connection upgraded by RouterGroup.WS. Context is the
+// handshake request, with its params and the values set by middlewares.
+type Conn struct {
+	*websocket.Conn
+	Context *Context
+}
+
+// WS registers a websocket endpoint for GET requests. The middlewares run
+// before the handshake, e.g. to authenticate, then the connection is
+// upgraded with engine.WebSocket and handed to handler. The connection
+// is closed when handler returns.
+func (group *RouterGroup) WS(pattern string, handler func(*Conn), middlewares ...HandlerFunc) {
+	upgrade := func(c *Context) {
+		ws, err := c.engine.WebSocket.Upgrade(c.Writer, c.Req, nil)
+		if err != nil {
+			// the handshake failure has been answered already
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		defer ws.Close()
+		handler(&Conn{Conn: ws, Context: c})
+	}
+	handlers := make([]HandlerFunc, 0, len(middlewares)+1)
+	handlers = append(handlers, middlewares...)
+	group.GET(pattern, append(handlers, upgrade)...)
+}
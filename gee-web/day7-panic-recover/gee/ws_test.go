@@ -0,0 +1,61 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gee/websocket"
+)
+
+func TestWS(t *testing.T) {
+	r := New()
+	r.Use(func(c *Context) {
+		c.Set("user", c.Query("user"))
+		c.Next()
+	})
+	auth := func(c *Context) {
+		if c.GetString("user") == "" {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	}
+	r.Group("/rooms").WS("/:room", func(conn *Conn) {
+		prefix := conn.Context.GetString("user") + "@" + conn.Context.Param("room") + ": "
+		for {
+			messageType, p, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(messageType, append([]byte(prefix), p...))
+		}
+	}, auth)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/rooms/gee"
+
+	if _, resp, err := websocket.Dial(url, nil); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatal("middlewares should run before the handshake")
+	}
+
+	conn, _, err := websocket.Dial(url+"?user=tutu", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.WriteMessage(websocket.TextMessage, []byte("hi"))
+	if _, p, err := conn.ReadMessage(); err != nil || string(p) != "tutu@gee: hi" {
+		t.Fatalf("handler should see params and keys, got %q %v", p, err)
+	}
+
+	resp, err := http.Get(srv.URL + "/rooms/gee?user=tutu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("plain http requests should be rejected, got %d", resp.StatusCode)
+	}
+}